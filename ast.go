@@ -0,0 +1,219 @@
+package mustache
+
+import "sort"
+
+const (
+	// NodeText is text outside of tags
+	NodeText NodeKind = iota
+	// NodeValue is a value tag, E.g. {{ name }} or {{{ name }}}
+	NodeValue
+	// NodeSection is a section, E.g. {{# items }}...{{/ items }}
+	NodeSection
+	// NodeInvertedSection is an inverted section, E.g. {{^ items }}...{{/ items }}
+	NodeInvertedSection
+	// NodeMessage is a message tag, E.g. {{_ greeting.welcome }}
+	NodeMessage
+	// NodePartial is a partial tag, E.g. {{> header }}
+	NodePartial
+	// NodeParent is a parent tag which renders a layout, E.g. {{< layouts/base }}...{{/ layouts/base }}
+	NodeParent
+	// NodeBlock is a block of a layout, E.g. {{$ content }}...{{/ content }}
+	NodeBlock
+)
+
+// NodeKind is the kind of a Node
+type NodeKind uint8
+
+func (k NodeKind) String() string {
+	switch k {
+	case NodeText:
+		return "text"
+	case NodeValue:
+		return "value"
+	case NodeSection:
+		return "section"
+	case NodeInvertedSection:
+		return "inverted section"
+	case NodeMessage:
+		return "message"
+	case NodePartial:
+		return "partial"
+	case NodeParent:
+		return "parent"
+	case NodeBlock:
+		return "block"
+	}
+
+	return "unknown"
+}
+
+// Position is the position of a Node within the source of its template
+type Position struct {
+	Offset int // Byte offset, starting at 0
+	Line   int // Line number, starting at 1
+	Column int // Byte offset within the line, starting at 1
+}
+
+// Node is a read-only node of the syntax tree of a Template
+type Node struct {
+	kind NodeKind
+	key  string
+	pos  Position
+	text string
+	raw  string
+
+	escape  bool
+	entries bool
+	count   string
+	filters []string
+
+	children []Node
+}
+
+// Kind will return the kind of the node
+func (n Node) Kind() NodeKind {
+	return n.kind
+}
+
+// Key will return the key of a value or section, the id of a message, or the name of a partial, parent or block
+func (n Node) Key() string {
+	return n.key
+}
+
+// Pos will return the position of the opening braces of the node's tag, or the start of a text node
+func (n Node) Pos() Position {
+	return n.pos
+}
+
+// Text will return the text of a text node
+func (n Node) Text() string {
+	return n.text
+}
+
+// Raw will return the exact source of the node. E.g. the tag of a value, or the opening tag, content
+// and closing tag of a section
+func (n Node) Raw() string {
+	return n.raw
+}
+
+// Escaped will return whether or not a value is escaped
+func (n Node) Escaped() bool {
+	return n.escape
+}
+
+// Entries will return whether or not a section iterates the key/value pairs of a map (E.g. {{# @entries prices }})
+func (n Node) Entries() bool {
+	return n.entries
+}
+
+// Count will return the key of the value which selects the plural form of a message, empty when not pluralized
+func (n Node) Count() string {
+	return n.count
+}
+
+// Filters will return the names of the filters applied to a value, in order
+func (n Node) Filters() []string {
+	return n.filters
+}
+
+// Children will return the nodes within a section, block or parent, and the nodes of a partial loaded from a
+// file. The positions of the nodes of a partial are within the partial's file
+func (n Node) Children() []Node {
+	return n.children
+}
+
+// Source will return a copy of the source the Template was parsed from
+func (t *Template) Source() []byte {
+	return append([]byte(nil), t.tmpl...)
+}
+
+// Nodes will return the syntax tree of the Template. The tree is built for each call, so it can be retained
+func (t *Template) Nodes() []Node {
+	return newNodeBuilder(t.tmpl).nodes(t)
+}
+
+// Walk will call fn for each node in depth-first order. The children of a node are walked when fn returns true
+func Walk(nodes []Node, fn func(Node) bool) {
+	for _, n := range nodes {
+		if fn(n) {
+			Walk(n.children, fn)
+		}
+	}
+}
+
+func newNodeBuilder(src []byte) *nodeBuilder {
+	nb := nodeBuilder{lines: []int{0}}
+	for i, b := range src {
+		if b == charNewline {
+			nb.lines = append(nb.lines, i+1)
+		}
+	}
+
+	return &nb
+}
+
+// nodeBuilder builds the nodes of a template and the sub-templates which share its source
+type nodeBuilder struct {
+	lines []int // Offsets of the start of each line
+}
+
+func (nb *nodeBuilder) nodes(t *Template) (ns []Node) {
+	ns = make([]Node, 0, len(t.tkns))
+	for _, tkn := range t.tkns {
+		var n Node
+		switch tt := tkn.(type) {
+		case tmplToken:
+			if tt.start == tt.end {
+				continue
+			}
+
+			n = Node{kind: NodeText, pos: nb.position(t.offset + tt.start), text: string(t.tmpl[tt.start:tt.end])}
+			n.raw = n.text
+		case valToken:
+			n = Node{kind: NodeValue, key: tt.key, pos: nb.position(t.offset + tt.pos), escape: tt.escape}
+			n.raw = string(t.tmpl[tt.pos:tt.end])
+			for _, fc := range tt.filters {
+				n.filters = append(n.filters, fc.name)
+			}
+		case sectionToken:
+			if len(tt.partial) > 0 {
+				// Partials loaded from a file are the root template of their own source
+				n = Node{kind: NodePartial, key: tt.partial, pos: nb.position(t.offset + tt.pos)}
+				n.raw = string(t.tmpl[tt.pos:tt.end])
+				n.children = newNodeBuilder(tt.t.tmpl).nodes(tt.t)
+				break
+			}
+
+			n = Node{kind: NodeSection, key: tt.key, pos: nb.position(t.offset + tt.pos), entries: tt.entries}
+			n.raw = string(t.tmpl[tt.pos:tt.end])
+			n.children = nb.nodes(tt.t)
+		case invertedSectionToken:
+			n = Node{kind: NodeInvertedSection, key: tt.key, pos: nb.position(t.offset + tt.pos)}
+			n.raw = string(t.tmpl[tt.pos:tt.end])
+			n.children = nb.nodes(tt.t)
+		case messageToken:
+			n = Node{kind: NodeMessage, key: tt.id, pos: nb.position(t.offset + tt.pos), count: tt.count}
+			n.raw = string(t.tmpl[tt.pos:tt.end])
+		case partialToken:
+			n = Node{kind: NodePartial, key: tt.name, pos: nb.position(t.offset + tt.pos)}
+			n.raw = string(t.tmpl[tt.pos:tt.end])
+		case parentToken:
+			n = Node{kind: NodeParent, key: tt.name, pos: nb.position(t.offset + tt.pos)}
+			n.raw = string(t.tmpl[tt.pos:tt.end])
+			n.children = nb.nodes(tt.t)
+		case blockToken:
+			n = Node{kind: NodeBlock, key: tt.name, pos: nb.position(t.offset + tt.pos)}
+			n.raw = string(t.tmpl[tt.pos:tt.end])
+			n.children = nb.nodes(tt.t)
+		}
+
+		ns = append(ns, n)
+	}
+
+	return
+}
+
+func (nb *nodeBuilder) position(offset int) Position {
+	line := sort.SearchInts(nb.lines, offset+1) - 1
+	return Position{Offset: offset, Line: line + 1, Column: offset - nb.lines[line] + 1}
+}
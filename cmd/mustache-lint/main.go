@@ -0,0 +1,272 @@
+// Command mustache-lint checks the templates within a directory. It reports:
+//   - syntax errors, including unclosed and mismatched sections. Parsing stops at the first error,
+//     so only the first syntax error of a file is reported
+//   - partials and layouts which do not exist
+//   - partial files which are never used, unless a template has a syntax error (its partials are unknown)
+//   - unescaped values (E.g. {{{ html }}}) within HTML templates
+//   - keys which do not match a pattern
+//
+// Usage:
+//
+//	mustache-lint [-ext .mustache,.html] [-partials partials] [-key-pattern ^[a-z][a-zA-Z.]*$] [-json] [dir]
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/itsmontoya/mustache"
+)
+
+const (
+	ruleSyntax         = "syntax"
+	ruleMissingPartial = "missing-partial"
+	ruleUnusedPartial  = "unused-partial"
+	ruleUnescapedHTML  = "unescaped-html"
+	ruleKeyPattern     = "key-pattern"
+)
+
+func main() {
+	var (
+		exts       = flag.String("ext", ".mustache,.html,.htm", "comma-separated extensions of template files")
+		htmlExts   = flag.String("html-ext", ".html,.htm", "comma-separated extensions of HTML templates, E.g. page.html or page.html.mustache")
+		partials   = flag.String("partials", "partials", "directory of partial files, which are reported when unused")
+		keyPattern = flag.String("key-pattern", "", "regular expression keys must match (disabled when empty)")
+		asJSON     = flag.Bool("json", false, "output issues as JSON")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mustache-lint [flags] [dir]")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	dir := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		dir = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	l := linter{
+		exts:     splitList(*exts),
+		htmlExts: splitList(*htmlExts),
+		partials: strings.Trim(*partials, "/"),
+		files:    make(map[string]string),
+		used:     make(map[string]bool),
+		set:      mustache.NewSet(),
+		issues:   []Issue{},
+	}
+
+	if len(*keyPattern) > 0 {
+		var err error
+		if l.keyPattern, err = regexp.Compile(*keyPattern); err != nil {
+			fmt.Fprintln(os.Stderr, "mustache-lint: invalid key pattern:", err)
+			os.Exit(2)
+		}
+	}
+
+	if err := l.lint(os.DirFS(dir)); err != nil {
+		fmt.Fprintln(os.Stderr, "mustache-lint:", err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		enc.Encode(l.issues)
+	} else {
+		for _, is := range l.issues {
+			fmt.Println(is)
+		}
+	}
+
+	if len(l.issues) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Issue is a problem found within a template
+type Issue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (is Issue) String() string {
+	if is.Line == 0 {
+		return fmt.Sprintf("%s: %s (%s)", is.File, is.Message, is.Rule)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", is.File, is.Line, is.Column, is.Message, is.Rule)
+}
+
+type linter struct {
+	exts       []string
+	htmlExts   []string
+	partials   string
+	keyPattern *regexp.Regexp
+
+	set   *mustache.Set
+	files map[string]string // Files by template name
+	used  map[string]bool   // Templates used as partials or layouts
+
+	issues []Issue
+	failed bool // A template has a syntax error
+}
+
+func (l *linter) lint(fsys fs.FS) (err error) {
+	var names []string
+	if err = fs.WalkDir(fsys, ".", func(fp string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !hasExt(fp, l.exts) {
+			return err
+		}
+
+		var src []byte
+		if src, err = fs.ReadFile(fsys, fp); err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(fp, path.Ext(fp))
+		l.files[name] = fp
+		names = append(names, name)
+
+		if err = l.set.Add(name, src); err != nil {
+			l.addParseError(fp, err)
+		}
+
+		return nil
+	}); err != nil {
+		return
+	}
+
+	for _, name := range names {
+		if t, ok := l.set.Lookup(name); ok {
+			l.lintTemplate(l.files[name], t)
+		}
+	}
+
+	// The partials used by templates with syntax errors are unknown
+	for _, name := range names {
+		if !l.failed && strings.HasPrefix(name, l.partials+"/") && !l.used[name] {
+			l.issues = append(l.issues, Issue{
+				File:    l.files[name],
+				Rule:    ruleUnusedPartial,
+				Message: "partial " + name + " is never used",
+			})
+		}
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i], l.issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		return a.Column < b.Column
+	})
+
+	return
+}
+
+func (l *linter) addParseError(fp string, err error) {
+	l.failed = true
+	is := Issue{File: fp, Rule: ruleSyntax, Message: err.Error()}
+
+	var pe *mustache.ParseError
+	if errors.As(err, &pe) {
+		is.Line, is.Column, is.Message = pe.Line, pe.Column, pe.Err.Error()
+	}
+
+	l.issues = append(l.issues, is)
+}
+
+func (l *linter) lintTemplate(fp string, t *mustache.Template) {
+	html := isHTML(fp, l.htmlExts)
+	mustache.Walk(t.Nodes(), func(n mustache.Node) bool {
+		switch n.Kind() {
+		case mustache.NodeValue:
+			if html && !n.Escaped() {
+				l.add(fp, n, ruleUnescapedHTML, "unescaped value "+n.Key()+" within HTML")
+			}
+
+			l.checkKey(fp, n, n.Key())
+		case mustache.NodeSection, mustache.NodeInvertedSection:
+			l.checkKey(fp, n, n.Key())
+		case mustache.NodeMessage:
+			l.checkKey(fp, n, n.Count())
+		case mustache.NodePartial, mustache.NodeParent:
+			l.used[n.Key()] = true
+			if _, ok := l.files[n.Key()]; !ok {
+				l.add(fp, n, ruleMissingPartial, n.Kind().String()+" "+n.Key()+" does not exist")
+			}
+		}
+
+		return true
+	})
+}
+
+func (l *linter) checkKey(fp string, n mustache.Node, key string) {
+	if l.keyPattern == nil || len(key) == 0 || key == "." || key[0] == '@' {
+		return
+	}
+
+	if !l.keyPattern.MatchString(key) {
+		l.add(fp, n, ruleKeyPattern, "key "+key+" does not match "+l.keyPattern.String())
+	}
+}
+
+func (l *linter) add(fp string, n mustache.Node, rule, msg string) {
+	pos := n.Pos()
+	l.issues = append(l.issues, Issue{
+		File:    fp,
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Rule:    rule,
+		Message: msg,
+	})
+}
+
+func hasExt(fp string, exts []string) bool {
+	ext := path.Ext(fp)
+	for _, e := range exts {
+		if e == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHTML will return whether or not a template is HTML, by its extension or the extension
+// preceding it (E.g. page.html.mustache)
+func isHTML(fp string, htmlExts []string) bool {
+	return hasExt(fp, htmlExts) || hasExt(strings.TrimSuffix(fp, path.Ext(fp)), htmlExts)
+}
+
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return
+}
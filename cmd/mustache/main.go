@@ -0,0 +1,211 @@
+// Command mustache renders a mustache template with data from JSON files, or stdin. E.g.
+//
+//	mustache -data config.json -escape none -o app.conf app.conf.mustache
+//	curl -s https://example.com/data.json | mustache -data - page.mustache
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/itsmontoya/mustache"
+)
+
+func main() {
+	var (
+		data     listFlag
+		partials listFlag
+
+		exts   = flag.String("ext", ".mustache", "comma-separated extensions of partial files")
+		escape = flag.String("escape", "html", "escaping of values: html, json (within a JSON string) or none")
+		strict = flag.Bool("strict", false, "fail when a value cannot be resolved")
+		out    = flag.String("o", "", "output file (default stdout)")
+	)
+
+	flag.Var(&data, "data", "JSON data file, - for stdin. Top-level keys of later files take precedence")
+	flag.Var(&partials, "partials", "directory of partials, referenced by their path without an extension (E.g. {{> emails/header }})")
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mustache [flags] <template>")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r := run{
+		tmpl:     flag.Arg(0),
+		data:     data,
+		partials: partials,
+		exts:     strings.Split(*exts, ","),
+		escape:   *escape,
+		strict:   *strict,
+		out:      *out,
+	}
+
+	if err := r.render(); err != nil {
+		fmt.Fprintln(os.Stderr, "mustache:", err)
+		os.Exit(1)
+	}
+}
+
+// listFlag is a flag which may be provided multiple times
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+type run struct {
+	tmpl     string
+	data     []string
+	partials []string
+	exts     []string
+	escape   string
+	strict   bool
+	out      string
+}
+
+func (r *run) render() (err error) {
+	var opts []mustache.Option
+	switch r.escape {
+	case "html":
+	case "json":
+		opts = append(opts, mustache.WithEscape(escapeJSON))
+	case "none":
+		opts = append(opts, mustache.WithEscape(func(b []byte) []byte { return b }))
+	default:
+		return fmt.Errorf("invalid escape %q, expected html, json or none", r.escape)
+	}
+
+	if r.strict {
+		opts = append(opts, mustache.OnMissingError())
+	}
+
+	var data interface{}
+	if data, err = r.loadData(); err != nil {
+		return
+	}
+
+	var t *mustache.Template
+	if t, err = r.parse(opts); err != nil {
+		return
+	}
+
+	var b []byte
+	if err = t.Render(data, func(rendered []byte) {
+		// The rendered bytes belong to a pooled buffer, so they are copied before it is returned
+		b = append(b, rendered...)
+	}); err != nil {
+		return
+	}
+
+	if len(r.out) == 0 {
+		_, err = os.Stdout.Write(b)
+		return
+	}
+
+	return os.WriteFile(r.out, b, 0644)
+}
+
+// parse will parse the template within a Set of the partials directories. Without partials directories,
+// partials are loaded from files relative to the template (E.g. {{> header }} loads header.mustache)
+func (r *run) parse(opts []mustache.Option) (t *mustache.Template, err error) {
+	var tmpl []byte
+	if tmpl, err = os.ReadFile(r.tmpl); err != nil {
+		return
+	}
+
+	if len(r.partials) == 0 {
+		return mustache.Parse(tmpl, filepath.Dir(r.tmpl), opts...)
+	}
+
+	s := mustache.NewSet(opts...)
+	for _, dir := range r.partials {
+		if err = s.ParseFS(os.DirFS(dir), r.exts); err != nil {
+			return nil, fmt.Errorf("error parsing partials of %s: %v", dir, err)
+		}
+	}
+
+	// The template is added last, so it is not replaced by a partial with the same name
+	name := strings.TrimSuffix(filepath.Base(r.tmpl), filepath.Ext(r.tmpl))
+	if err = s.Add(name, tmpl); err != nil {
+		return
+	}
+
+	t, _ = s.Lookup(name)
+	return
+}
+
+// loadData will decode each data source. A single source is returned as is (E.g. a list), multiple
+// sources must be objects and are merged by their top-level keys
+func (r *run) loadData() (data interface{}, err error) {
+	merged := make(map[string]interface{})
+	for i, src := range r.data {
+		var v interface{}
+		if v, err = r.decode(src); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %v", src, err)
+		}
+
+		if len(r.data) == 1 {
+			return v, nil
+		}
+
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error merging %s: data #%d is not an object", src, i+1)
+		}
+
+		for k, mv := range m {
+			merged[k] = mv
+		}
+	}
+
+	if len(r.data) == 0 {
+		return
+	}
+
+	return merged, nil
+}
+
+func (r *run) decode(src string) (v interface{}, err error) {
+	var b []byte
+	if src == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(src)
+	}
+
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(b, &v)
+	return
+}
+
+// escapeJSON will escape a value so it can be written within a JSON string
+func escapeJSON(b []byte) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(string(b))
+
+	// The quotes and trailing newline of the encoded string are trimmed
+	out := buf.Bytes()
+	return out[1 : len(out)-2]
+}
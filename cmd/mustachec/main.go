@@ -0,0 +1,198 @@
+// Command mustachec compiles a mustache template to a Go func which renders a data type of the current package.
+// It is intended to be run by go generate, E.g.
+//
+//	//go:generate mustachec -type Page -func RenderPage page.mustache
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+func main() {
+	var (
+		typ    = flag.String("type", "", "name of the data type, E.g. Page or *Page")
+		fn     = flag.String("func", "", "name of the generated func (default Render<type>)")
+		out    = flag.String("o", "", "output file (default <template>_mustache.go)")
+		strict = flag.Bool("strict", false, "fail when a key cannot be resolved for the data type")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mustachec -type <type> [-func <name>] [-o <file>] [-strict] <template>")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	if flag.NArg() != 1 || len(*typ) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := compilation{
+		Tmpl:   flag.Arg(0),
+		Type:   *typ,
+		Func:   *fn,
+		Out:    *out,
+		Strict: *strict,
+	}
+
+	if err := c.run(); err != nil {
+		fmt.Fprintln(os.Stderr, "mustachec:", err)
+		os.Exit(1)
+	}
+}
+
+// compilation is also the data of the bootstrap template
+type compilation struct {
+	Tmpl   string
+	Type   string
+	Func   string
+	Out    string
+	Strict bool
+
+	Pkg     string
+	PkgPath string
+}
+
+// run will compile the template by running a bootstrap program, as the data type can only be
+// reflected on by a program which imports the package of the type
+func (c *compilation) run() (err error) {
+	if c.Pkg = os.Getenv("GOPACKAGE"); len(c.Pkg) == 0 {
+		return fmt.Errorf("GOPACKAGE is not set, mustachec must be run by go generate")
+	}
+
+	if c.Pkg == "main" {
+		return fmt.Errorf("package main cannot be imported, the data type must be within an importable package")
+	}
+
+	if len(c.Func) == 0 {
+		c.Func = "Render" + strings.TrimPrefix(c.Type, "*")
+	}
+
+	if len(c.Out) == 0 {
+		c.Out = strings.TrimSuffix(c.Tmpl, filepath.Ext(c.Tmpl)) + "_mustache.go"
+	}
+
+	if c.Tmpl, err = filepath.Abs(c.Tmpl); err != nil {
+		return
+	}
+
+	if c.Out, err = filepath.Abs(c.Out); err != nil {
+		return
+	}
+
+	var b []byte
+	if b, err = exec.Command("go", "list", "-f", "{{.ImportPath}}", ".").Output(); err != nil {
+		return fmt.Errorf("error finding import path: %v", err)
+	}
+
+	c.PkgPath = strings.TrimSpace(string(b))
+
+	// A stub is written so the package still builds when a previous output is out of date
+	if err = c.writeStub(); err != nil {
+		return
+	}
+
+	var dir string
+	if dir, err = os.MkdirTemp(".", "mustachec-bootstrap-"); err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	var f *os.File
+	if f, err = os.Create(filepath.Join(dir, "main.go")); err != nil {
+		return
+	}
+
+	err = bootstrap.Execute(f, c)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return
+	}
+
+	cmd := exec.Command("go", "run", "./"+filepath.Base(dir))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (c *compilation) writeStub() error {
+	stub := fmt.Sprintf("// Code generated by mustachec. DO NOT EDIT.\n\npackage %s\n\nimport \"io\"\n\n"+
+		"func %s(w io.Writer, data %s) (err error) {\n\treturn\n}\n", c.Pkg, c.Func, c.Type)
+	return os.WriteFile(c.Out, []byte(stub), 0644)
+}
+
+// Pointer returns whether or not the data type is a pointer
+func (c *compilation) Pointer() bool {
+	return strings.HasPrefix(c.Type, "*")
+}
+
+// TypeName returns the name of the data type without a pointer
+func (c *compilation) TypeName() string {
+	return strings.TrimPrefix(c.Type, "*")
+}
+
+var bootstrap = template.Must(template.New("bootstrap").Parse(`// Code generated by mustachec. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/itsmontoya/mustache"
+
+	pkg {{ printf "%q" .PkgPath }}
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "mustachec:", err)
+		os.Exit(1)
+	}
+}
+
+func run() (err error) {
+	var (
+		tmpl []byte
+		t    *mustache.Template
+		src  []byte
+		opts []mustache.Option
+	)
+
+	if tmpl, err = os.ReadFile({{ printf "%q" .Tmpl }}); err != nil {
+		return
+	}
+{{ if .Strict }}
+	opts = append(opts, mustache.OnMissingError())
+{{ end }}
+	if t, err = mustache.Parse(tmpl, filepath.Dir({{ printf "%q" .Tmpl }}), opts...); err != nil {
+		return
+	}
+
+	typ := reflect.TypeOf((*pkg.{{ .TypeName }})(nil)).Elem()
+{{- if .Pointer }}
+	typ = reflect.PointerTo(typ)
+{{- end }}
+
+	if src, err = mustache.Compile(t, typ, mustache.CompileOptions{
+		Package: {{ printf "%q" .Pkg }},
+		PkgPath: {{ printf "%q" .PkgPath }},
+		Func:    {{ printf "%q" .Func }},
+	}); err != nil {
+		return
+	}
+
+	return os.WriteFile({{ printf "%q" .Out }}, src, 0644)
+}
+`))
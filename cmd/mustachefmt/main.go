@@ -0,0 +1,163 @@
+// Command mustachefmt formats mustache templates with normalized tag whitespace, and indents standalone
+// section tags with -standalone. Without paths, stdin is formatted to stdout. Directories are walked for
+// files with the provided extensions. E.g.
+//
+//	mustachefmt -w templates
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/itsmontoya/mustache"
+)
+
+func main() {
+	var (
+		exts   = flag.String("ext", ".mustache,.html,.htm", "comma-separated extensions of template files within directories")
+		list   = flag.Bool("l", false, "list files whose formatting differs")
+		write  = flag.Bool("w", false, "write the result to the source file rather than stdout")
+		indent = flag.Bool("standalone", false, "indent standalone section tags, for templates rendered with WithStandaloneLines")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mustachefmt [-l] [-w] [-standalone] [-ext .mustache] [path ...]")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	f := fmter{
+		exts:  strings.Split(*exts, ","),
+		list:  *list,
+		write: *write,
+	}
+
+	if *indent {
+		f.opts = append(f.opts, mustache.WithStandaloneLines())
+	}
+
+	if flag.NArg() == 0 {
+		if f.write {
+			fmt.Fprintln(os.Stderr, "mustachefmt: cannot use -w with stdin")
+			os.Exit(2)
+		}
+
+		if err := f.formatStdin(); err != nil {
+			fmt.Fprintln(os.Stderr, "mustachefmt:", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	for _, p := range flag.Args() {
+		f.formatPath(p)
+	}
+
+	if f.failed {
+		os.Exit(1)
+	}
+}
+
+type fmter struct {
+	exts  []string
+	list  bool
+	write bool
+	opts  []mustache.Option
+
+	failed bool // An error was reported
+}
+
+func (f *fmter) formatStdin() (err error) {
+	var src, out []byte
+	if src, err = io.ReadAll(os.Stdin); err != nil {
+		return
+	}
+
+	if out, err = mustache.Format(src, f.opts...); err != nil {
+		return fmt.Errorf("<stdin>: %v", err)
+	}
+
+	if f.list {
+		if !bytes.Equal(src, out) {
+			fmt.Println("<stdin>")
+		}
+
+		return
+	}
+
+	_, err = os.Stdout.Write(out)
+	return
+}
+
+// formatPath will format a file, or every template file within a directory. Errors are reported as
+// they occur, so the remaining files are still formatted
+func (f *fmter) formatPath(p string) {
+	err := filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir():
+			return nil
+		case fp != p && !hasExt(fp, f.exts):
+			// Files within directories are filtered by extension, files provided as arguments are not
+			return nil
+		}
+
+		f.report(f.formatFile(fp))
+		return nil
+	})
+
+	f.report(err)
+}
+
+func (f *fmter) report(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mustachefmt:", err)
+		f.failed = true
+	}
+}
+
+func (f *fmter) formatFile(fp string) (err error) {
+	var src, out []byte
+	if src, err = os.ReadFile(fp); err != nil {
+		return
+	}
+
+	if out, err = mustache.Format(src, f.opts...); err != nil {
+		return fmt.Errorf("%s: %v", fp, err)
+	}
+
+	changed := !bytes.Equal(src, out)
+	if f.list && changed {
+		fmt.Println(fp)
+	}
+
+	switch {
+	case f.write:
+		if changed {
+			err = os.WriteFile(fp, out, 0644)
+		}
+	case !f.list:
+		_, err = os.Stdout.Write(out)
+	}
+
+	return
+}
+
+func hasExt(fp string, exts []string) bool {
+	ext := filepath.Ext(fp)
+	for _, e := range exts {
+		if e == ext {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,722 @@
+package mustache
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/itsmontoya/escapist"
+	"github.com/missionMeteora/toolkit/errors"
+)
+
+// ErrNotCompilable is returned when a template uses a feature which cannot be compiled to Go source
+const ErrNotCompilable = errors.Error("template cannot be compiled")
+
+// importPath is the import path of this package, used by compiled templates
+const importPath = "github.com/itsmontoya/mustache"
+
+var aficionadoType = reflect.TypeOf((*Aficionado)(nil)).Elem()
+
+// CompileOptions configure the Go source generated by Compile
+type CompileOptions struct {
+	// Package is the name of the package of the generated file
+	Package string
+	// PkgPath is the import path of the package of the generated file, types of other packages are imported
+	PkgPath string
+	// Func is the name of the generated func
+	Func string
+}
+
+// CompileError is returned when a key of a template cannot be compiled for a type
+type CompileError struct {
+	Key  string
+	Type reflect.Type // Nil when the key is resolved without a context
+	Err  error
+}
+
+func (e *CompileError) Error() string {
+	if e.Type == nil {
+		return e.Err.Error() + ": " + e.Key
+	}
+
+	return e.Err.Error() + ": " + e.Key + " (" + e.Type.String() + ")"
+}
+
+// Unwrap will return the cause of the error
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// Compile will generate the Go source of a func(w io.Writer, data T) error which renders the Template
+// for the data type typ, without resolving keys or switching on tokens when rendered.
+// Keys are resolved when compiled: struct fields by their mustache tag (E.g. `mustache:"name"`) or by
+// case-insensitive name, and map values by key. Filters, message tags, Locales, the partials and layouts
+// of a Set, WithEscape and sections of interface values cannot be compiled. Limits are not enforced by the generated func
+func Compile(t *Template, typ reflect.Type, co CompileOptions) (src []byte, err error) {
+	switch {
+	case t.o.locale != nil:
+		return nil, &CompileError{Key: "locale", Err: ErrNotCompilable}
+	case t.o.missing == missingFunc:
+		return nil, &CompileError{Key: "OnMissingFunc", Err: ErrNotCompilable}
+	case t.o.escape != nil:
+		return nil, &CompileError{Key: "WithEscape", Err: ErrNotCompilable}
+	}
+
+	c := compiler{
+		o:       t.o,
+		co:      co,
+		imports: map[string]struct{}{"io": {}},
+	}
+
+	name := c.typeName(typ)
+	if err = c.compileRoot(t, typ); err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by mustachec. DO NOT EDIT.\n\npackage %s\n\nimport (\n", co.Package)
+	var std, other []string
+	for imp := range c.imports {
+		if first, _, _ := strings.Cut(imp, "/"); strings.Contains(first, ".") {
+			other = append(other, imp)
+		} else {
+			std = append(std, imp)
+		}
+	}
+
+	sort.Strings(std)
+	sort.Strings(other)
+	for _, imp := range std {
+		fmt.Fprintf(&buf, "\t%q\n", imp)
+	}
+
+	if len(other) > 0 {
+		buf.WriteString("\n")
+	}
+
+	for _, imp := range other {
+		fmt.Fprintf(&buf, "\t%q\n", imp)
+	}
+
+	fmt.Fprintf(&buf, ")\n\n// %s will render the template with the provided data to a writer\n", co.Func)
+	fmt.Fprintf(&buf, "func %s(w io.Writer, data %s) (err error) {\n", co.Func, name)
+	buf.Write(c.buf.Bytes())
+	buf.WriteString("return\n}\n")
+	return format.Source(buf.Bytes())
+}
+
+type compiler struct {
+	buf bytes.Buffer
+
+	o       *options
+	co      CompileOptions
+	imports map[string]struct{}
+	vars    int // Number of variables declared, used for unique names
+
+	returned bool // The current block has returned, the remaining tokens of the block are unreachable
+}
+
+// compileScope is the context of a compiled (sub-)template
+type compileScope struct {
+	expr string       // Go expression of the context
+	typ  reflect.Type // Nil when there is no context
+	loop *compileLoop
+}
+
+// with will return a scope for a value within the current loop
+func (sc compileScope) with(expr string, typ reflect.Type) compileScope {
+	return compileScope{expr: expr, typ: typ, loop: sc.loop}
+}
+
+// compileLoop holds the Go expressions of the loop metadata of a compiled section
+type compileLoop struct {
+	index  string
+	length string
+
+	entry bool
+	key   string
+	value *compileScope
+}
+
+// binding is a key resolved to a Go expression
+type binding struct {
+	init string // Statement which declares the value, E.g. v1, ok1 := data.M["key"]
+	ok   string // Expression which is false when the value is missing, empty when the value is always set
+	expr string
+	typ  reflect.Type
+}
+
+func (c *compiler) compileRoot(t *Template, typ reflect.Type) (err error) {
+	if typ.Kind() != reflect.Ptr {
+		return c.compileTokens(t, compileScope{expr: "data", typ: typ})
+	}
+
+	// Nil data is rendered without a context, so inverted sections are still rendered
+	c.printf("if data != nil {\n")
+	if err = c.compileTokens(t, compileScope{expr: "data", typ: typ.Elem()}); err != nil {
+		return
+	}
+
+	c.elseBlock()
+	if err = c.compileTokens(t, compileScope{}); err != nil {
+		return
+	}
+
+	c.closeBlock()
+	return
+}
+
+func (c *compiler) compileTokens(t *Template, sc compileScope) (err error) {
+	for _, tkn := range t.tkns {
+		switch tt := tkn.(type) {
+		case tmplToken:
+			if tt.start == tt.end {
+				break
+			}
+
+			c.printf("if _, err = io.WriteString(w, %q); err != nil {\nreturn\n}\n", t.tmpl[tt.start:tt.end])
+		case valToken:
+			err = c.compileValue(t, tt, sc)
+		case sectionToken:
+			err = c.compileSection(tt, sc)
+		case invertedSectionToken:
+			err = c.compileInvertedSection(tt, sc)
+		case messageToken:
+			err = &CompileError{Key: tt.id, Err: ErrNotCompilable}
+		case partialToken:
+			err = &CompileError{Key: tt.name, Err: ErrNotCompilable}
+		case parentToken:
+			err = &CompileError{Key: tt.name, Err: ErrNotCompilable}
+		case blockToken:
+			err = &CompileError{Key: tt.name, Err: ErrNotCompilable}
+		}
+
+		if err != nil || c.returned {
+			break
+		}
+	}
+
+	return
+}
+
+func (c *compiler) compileValue(t *Template, tkn valToken, sc compileScope) (err error) {
+	if len(tkn.filters) > 0 {
+		return &CompileError{Key: tkn.key, Type: sc.typ, Err: ErrNotCompilable}
+	}
+
+	b, found := c.resolve(tkn.key, sc)
+	if !found {
+		return c.compileMissing(t, tkn, sc)
+	}
+
+	if len(b.ok) > 0 {
+		c.printf("if %s; %s {\n", b.init, b.ok)
+	}
+
+	// Values which can be nil are missing when nil
+	missing := c.o.missing != missingIgnore
+	switch {
+	case isBuiltin(b.typ, reflect.String):
+		c.use(importPath)
+		c.printf("if err = mustache.WriteString(w, %s, %t); err != nil {\nreturn\n}\n", b.expr, tkn.escape)
+	case isBuiltin(b.typ, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64):
+		c.use(importPath)
+		c.printf("if err = mustache.WriteInt(w, int64(%s)); err != nil {\nreturn\n}\n", b.expr)
+	case isBuiltin(b.typ, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64):
+		c.use(importPath)
+		c.printf("if err = mustache.WriteUint(w, uint64(%s)); err != nil {\nreturn\n}\n", b.expr)
+	case missing:
+		c.use(importPath)
+		c.printf("if ok, werr := mustache.WriteValue(w, %s, %t); werr != nil {\nreturn werr\n} else if !ok {\n", b.expr, tkn.escape)
+		c.compileMissingAction(t, tkn)
+		c.closeBlock()
+	default:
+		c.use(importPath)
+		c.printf("if _, err = mustache.WriteValue(w, %s, %t); err != nil {\nreturn\n}\n", b.expr, tkn.escape)
+	}
+
+	if len(b.ok) > 0 && missing {
+		c.elseBlock()
+		c.compileMissingAction(t, tkn)
+		c.closeBlock()
+	} else if len(b.ok) > 0 {
+		c.closeBlock()
+	}
+
+	return
+}
+
+// compileMissing compiles a value whose key cannot be resolved for the type of its context
+func (c *compiler) compileMissing(t *Template, tkn valToken, sc compileScope) (err error) {
+	if c.o.missing == missingError && sc.typ != nil {
+		return &CompileError{Key: tkn.key, Type: sc.typ, Err: ErrMissingKey}
+	}
+
+	c.compileMissingAction(t, tkn)
+	return
+}
+
+// compileMissingAction compiles the action of the missing value option, which is written in place of a missing value
+func (c *compiler) compileMissingAction(t *Template, tkn valToken) {
+	switch c.o.missing {
+	case missingError:
+		c.use(importPath)
+		c.printf("return mustache.ErrMissingKey\n")
+		c.returned = true
+	case missingLiteral:
+		c.printf("if _, err = w.Write([]byte(%q)); err != nil {\nreturn\n}\n", c.o.placeholder)
+	case missingRaw:
+		// The source of the tag is written, as it is by the Renderer
+		c.printf("if _, err = io.WriteString(w, %q); err != nil {\nreturn\n}\n", t.tmpl[tkn.pos:tkn.end])
+	}
+}
+
+func (c *compiler) compileSection(tkn sectionToken, sc compileScope) (err error) {
+	if tkn.key == "." && !tkn.entries && !canBeEmpty(sc.typ) {
+		// The current context is truthy unless it is an empty or nil list, map or pointer
+		return c.compileTokens(tkn.t, sc)
+	}
+
+	b, found := c.resolve(tkn.key, sc)
+	if !found {
+		return
+	}
+
+	if len(b.ok) > 0 {
+		c.printf("if %s; %s {\n_ = %s\n", b.init, b.ok, b.expr)
+		defer c.closeBlock()
+	}
+
+	if tkn.entries {
+		return c.compileEntries(tkn, sc, b)
+	}
+
+	return c.compileSectionValue(tkn, sc, b.expr, b.typ)
+}
+
+func (c *compiler) compileSectionValue(tkn sectionToken, sc compileScope, expr string, typ reflect.Type) (err error) {
+	if typ.Kind() != reflect.Map && typ.Implements(aficionadoType) {
+		// The fields of an Aficionado are resolved by its MarshalMustache
+		return &CompileError{Key: tkn.key, Type: typ, Err: ErrNotCompilable}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		c.printf("if %s {\n", expr)
+		err = c.compileTokens(tkn.t, sc)
+		c.closeBlock()
+
+	case reflect.String:
+		c.printf("if len(%s) > 0 {\n", expr)
+		err = c.compileTokens(tkn.t, sc.with(expr, typ))
+		c.closeBlock()
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Struct:
+		err = c.compileTokens(tkn.t, sc.with(expr, typ))
+
+	case reflect.Ptr:
+		v := c.newVar("p")
+		c.printf("if %s := %s; %s != nil {\n", v, expr, v)
+		if typ.Elem().Kind() == reflect.Struct {
+			// Fields are selected through the pointer
+			err = c.compileSectionValue(tkn, sc, v, typ.Elem())
+		} else {
+			err = c.compileSectionValue(tkn, sc, "(*"+v+")", typ.Elem())
+		}
+
+		c.closeBlock()
+
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String {
+			return &CompileError{Key: tkn.key, Type: typ, Err: ErrUnsupportedType}
+		}
+
+		v := c.newVar("m")
+		c.printf("if %s := %s; len(%s) > 0 {\n", v, expr, v)
+		err = c.compileTokens(tkn.t, sc.with(v, typ))
+		c.closeBlock()
+
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are values
+			c.printf("if len(%s) > 0 {\n", expr)
+			err = c.compileTokens(tkn.t, sc.with(expr, typ))
+			c.closeBlock()
+			return
+		}
+
+		s, i, v := c.newVar("s"), c.newVar("i"), c.newVar("v")
+		c.printf("if %s := %s; len(%s) > 0 {\nfor %s, %s := range %s {\n_, _ = %s, %s\n", s, expr, s, i, v, s, i, v)
+		err = c.compileTokens(tkn.t, compileScope{
+			expr: v,
+			typ:  typ.Elem(),
+			loop: &compileLoop{index: i, length: "len(" + s + ")"},
+		})
+
+		c.closeBlock()
+		c.closeBlock()
+
+	default:
+		err = &CompileError{Key: tkn.key, Type: typ, Err: ErrNotCompilable}
+	}
+
+	return
+}
+
+// compileEntries compiles a section which iterates the key/value pairs of a map, in sorted key order
+func (c *compiler) compileEntries(tkn sectionToken, sc compileScope, b binding) (err error) {
+	typ, expr := b.typ, b.expr
+	if typ.Kind() == reflect.Ptr {
+		v := c.newVar("p")
+		c.printf("if %s := %s; %s != nil {\n", v, expr, v)
+		defer c.closeBlock()
+		typ, expr = typ.Elem(), "(*"+v+")"
+	}
+
+	if typ.Kind() != reflect.Map || typ.Key().Kind() != reflect.String {
+		return &CompileError{Key: tkn.key, Type: typ, Err: ErrUnsupportedType}
+	}
+
+	m, ks, i, k, v := c.newVar("m"), c.newVar("ks"), c.newVar("i"), c.newVar("k"), c.newVar("v")
+	c.use(importPath)
+	c.printf("%s := %s\n%s := mustache.SortedKeys(%s)\n", m, expr, ks, m)
+	c.printf("for %s, %s := range %s {\n%s := %s[%s]\n_, _ = %s, %s\n", i, k, ks, v, m, k, i, v)
+	value := compileScope{expr: v, typ: typ.Elem()}
+	value.loop = &compileLoop{index: i, length: "len(" + ks + ")", entry: true, key: k, value: &value}
+	err = c.compileTokens(tkn.t, value)
+	c.closeBlock()
+	return
+}
+
+func (c *compiler) compileInvertedSection(tkn invertedSectionToken, sc compileScope) (err error) {
+	if tkn.key == "." && !canBeEmpty(sc.typ) {
+		if sc.typ != nil {
+			// The current context is always truthy
+			return
+		}
+
+		return c.compileTokens(tkn.t, sc)
+	}
+
+	b, found := c.resolve(tkn.key, sc)
+	if !found {
+		// Missing values are falsy
+		return c.compileTokens(tkn.t, sc)
+	}
+
+	var cond string
+	if cond, err = c.falsy(tkn.key, b.expr, b.typ); err != nil || len(cond) == 0 {
+		return
+	}
+
+	if len(b.ok) > 0 {
+		cond = "!" + b.ok + " || " + cond
+		c.printf("if %s; %s {\n", b.init, cond)
+	} else {
+		c.printf("if %s {\n", cond)
+	}
+
+	err = c.compileTokens(tkn.t, sc)
+	c.closeBlock()
+	return
+}
+
+// canBeEmpty will return true when the values of a type can be empty or nil, so the type is not always truthy
+func canBeEmpty(typ reflect.Type) bool {
+	if typ == nil {
+		return false
+	}
+
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan, reflect.Ptr:
+		return true
+	}
+
+	return false
+}
+
+// falsy will return the Go expression which is true when a value is falsy, empty when a value is never falsy
+func (c *compiler) falsy(key, expr string, typ reflect.Type) (cond string, err error) {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "!" + expr, nil
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return "len(" + expr + ") == 0", nil
+	case reflect.Ptr:
+		if cond, err = c.falsy(key, "(*"+expr+")", typ.Elem()); err != nil || len(cond) == 0 {
+			return expr + " == nil", err
+		}
+
+		return expr + " == nil || " + cond, nil
+	case reflect.Interface:
+		c.use(importPath)
+		return "mustache.IsFalsy(" + expr + ")", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Struct:
+		return "", nil
+	}
+
+	return "", &CompileError{Key: key, Type: typ, Err: ErrNotCompilable}
+}
+
+// resolve will resolve a key to a Go expression for the type of a context
+func (c *compiler) resolve(key string, sc compileScope) (b binding, found bool) {
+	if len(key) > 0 && key[0] == charAt {
+		return sc.loop.get(key)
+	}
+
+	if key == "." {
+		return binding{expr: sc.expr, typ: sc.typ}, sc.typ != nil
+	}
+
+	typ := sc.typ
+	if typ == nil {
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		var f reflect.StructField
+		if f, found = c.field(typ, key); found {
+			b = binding{expr: sc.expr + "." + f.Name, typ: f.Type}
+		}
+
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String {
+			return
+		}
+
+		v, ok := c.newVar("v"), c.newVar("ok")
+		b = binding{
+			init: v + ", " + ok + " := " + sc.expr + "[" + strconv.Quote(key) + "]",
+			ok:   ok,
+			expr: v,
+			typ:  typ.Elem(),
+		}
+
+		found = true
+	}
+
+	return
+}
+
+// field will find the field of a struct for a key, by mustache tag and then by case-insensitive name.
+// Fields promoted through embedded pointers are not used, as they may be nil
+func (c *compiler) field(typ reflect.Type, key string) (f reflect.StructField, ok bool) {
+	var byName []reflect.StructField
+	for _, vf := range reflect.VisibleFields(typ) {
+		if !c.accessible(typ, vf) {
+			continue
+		}
+
+		tag, _, _ := strings.Cut(vf.Tag.Get("mustache"), ",")
+		switch {
+		case tag == "-":
+		case tag == key:
+			return vf, true
+		case len(tag) == 0 && strings.EqualFold(vf.Name, key):
+			byName = append(byName, vf)
+		}
+	}
+
+	if len(byName) == 0 {
+		return
+	}
+
+	return byName[0], true
+}
+
+// accessible will return whether or not a field can be selected by the generated func
+func (c *compiler) accessible(typ reflect.Type, f reflect.StructField) bool {
+	if !f.IsExported() && typ.PkgPath() != c.co.PkgPath {
+		return false
+	}
+
+	for i := range f.Index[:len(f.Index)-1] {
+		if typ.FieldByIndex(f.Index[:i+1]).Type.Kind() == reflect.Ptr {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (l *compileLoop) get(key string) (b binding, found bool) {
+	if l == nil {
+		return
+	}
+
+	b.typ, found = reflect.TypeOf(true), true
+	switch key {
+	case "@index":
+		b.expr, b.typ = l.index, reflect.TypeOf(0)
+	case "@number":
+		b.expr, b.typ = l.index+" + 1", reflect.TypeOf(0)
+	case "@first":
+		b.expr = l.index + " == 0"
+	case "@last":
+		b.expr = l.index + " == " + l.length + "-1"
+	case "@odd":
+		b.expr = l.index + "%2 == 1"
+	case "@even":
+		b.expr = l.index + "%2 == 0"
+	case "@length":
+		b.expr, b.typ = l.length, reflect.TypeOf(0)
+	case "@key":
+		b.expr, b.typ, found = l.key, reflect.TypeOf(""), l.entry
+	case "@value":
+		if found = l.entry; found {
+			b.expr, b.typ = l.value.expr, l.value.typ
+		}
+	default:
+		found = false
+	}
+
+	if found && b.typ.Kind() == reflect.Bool {
+		b.expr = "(" + b.expr + ")"
+	}
+
+	return
+}
+
+// typeName will return the name of a type within the generated file, importing the packages it references
+func (c *compiler) typeName(typ reflect.Type) string {
+	if len(typ.Name()) > 0 {
+		switch typ.PkgPath() {
+		case "", c.co.PkgPath:
+			return typ.Name()
+		}
+
+		c.use(typ.PkgPath())
+		return typ.String()
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return "*" + c.typeName(typ.Elem())
+	case reflect.Slice:
+		return "[]" + c.typeName(typ.Elem())
+	case reflect.Array:
+		return "[" + strconv.Itoa(typ.Len()) + "]" + c.typeName(typ.Elem())
+	case reflect.Map:
+		return "map[" + c.typeName(typ.Key()) + "]" + c.typeName(typ.Elem())
+	}
+
+	return typ.String()
+}
+
+// closeBlock will close a block of the generated func, the code following the block is reachable
+func (c *compiler) closeBlock() {
+	c.printf("}\n")
+	c.returned = false
+}
+
+// elseBlock will close the if branch of a block and open the else branch
+func (c *compiler) elseBlock() {
+	c.printf("} else {\n")
+	c.returned = false
+}
+
+func (c *compiler) newVar(prefix string) string {
+	c.vars++
+	return prefix + strconv.Itoa(c.vars)
+}
+
+func (c *compiler) use(imp string) {
+	c.imports[imp] = struct{}{}
+}
+
+func (c *compiler) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&c.buf, format, args...)
+}
+
+// isBuiltin will return whether or not a type is an unnamed builtin type of one of the provided kinds
+func isBuiltin(typ reflect.Type, kinds ...reflect.Kind) bool {
+	if len(typ.PkgPath()) > 0 || len(typ.Name()) == 0 {
+		return false
+	}
+
+	for _, k := range kinds {
+		if typ.Kind() == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteString will write a string to a writer, escaping it when escape is true. Used by compiled templates
+func WriteString(w io.Writer, s string, escape bool) (err error) {
+	if !escape {
+		_, err = io.WriteString(w, s)
+		return
+	}
+
+	_, err = w.Write(escapist.Escape([]byte(s)))
+	return
+}
+
+// WriteInt will write an integer to a writer. Used by compiled templates
+func WriteInt(w io.Writer, i int64) (err error) {
+	var buf [20]byte
+	_, err = w.Write(strconv.AppendInt(buf[:0], i, 10))
+	return
+}
+
+// WriteUint will write an unsigned integer to a writer. Used by compiled templates
+func WriteUint(w io.Writer, u uint64) (err error) {
+	var buf [20]byte
+	_, err = w.Write(strconv.AppendUint(buf[:0], u, 10))
+	return
+}
+
+// WriteValue will write a value to a writer, escaping it when escape is true. ok is false when the
+// value is missing (E.g. a nil pointer). Used by compiled templates
+func WriteValue(w io.Writer, v interface{}, escape bool) (ok bool, err error) {
+	var (
+		b       []byte
+		invalid bool
+	)
+
+	if b, ok, invalid = getValueBytes(v); invalid {
+		return false, ErrUnsupportedType
+	} else if !ok {
+		return
+	}
+
+	if escape {
+		b = escapist.Escape(b)
+	}
+
+	_, err = w.Write(b)
+	return
+}
+
+// IsFalsy will return whether or not a value is falsy. Used by compiled templates
+func IsFalsy(v interface{}) bool {
+	// True values are sections of their parent, so a parent is provided which is never nil
+	_, ok, _ := getSection(Value{}, v)
+	return !ok
+}
+
+// SortedKeys will return the keys of a map, sorted. Used by compiled templates
+func SortedKeys[K ~string, V any](m map[K]V) (keys []K) {
+	keys = make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	return
+}
@@ -0,0 +1,510 @@
+package mustache
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/missionMeteora/toolkit/errors"
+)
+
+const (
+	// ErrUnknownFilter is returned when a tag references a filter which has not been registered
+	ErrUnknownFilter = errors.Error("unknown filter")
+
+	// ErrInvalidFilterArgs is returned when a filter is provided invalid arguments
+	ErrInvalidFilterArgs = errors.Error("invalid filter arguments")
+)
+
+// Filter transforms a value before it is rendered. Arguments are provided as they are written in the
+// tag, with quoted arguments unquoted. E.g. {{ title | truncate 40 "..." }} calls truncate with ["40", "..."].
+// The Locale of the render is provided, and is nil when no Locale has been set
+type Filter func(v interface{}, args []string, loc *Locale) (interface{}, error)
+
+// Filters are a set of named filters
+type Filters map[string]Filter
+
+// WithFilters will make the provided filters available to a Template. Filters with the same name as a
+// standard filter will take precedence
+func WithFilters(fs Filters) Option {
+	return func(o *options) {
+		if o.filters == nil {
+			o.filters = make(Filters, len(fs))
+		}
+
+		for name, fn := range fs {
+			o.filters[name] = fn
+		}
+	}
+}
+
+// stdFilters are available to every Template. Missing values skip every standard filter except
+// default, so they are still handled as missing values
+var stdFilters = Filters{
+	// Strings
+	"upper":    skipMissing(filterUpper),
+	"lower":    skipMissing(filterLower),
+	"title":    skipMissing(filterTitle),
+	"trim":     skipMissing(filterTrim),
+	"truncate": skipMissing(filterTruncate),
+	"replace":  skipMissing(filterReplace),
+	"default":  filterDefault,
+	"urlquery": skipMissing(filterURLQuery),
+
+	// Numbers
+	"number":   skipMissing(filterNumber),
+	"fixed":    skipMissing(filterFixed),
+	"percent":  skipMissing(filterPercent),
+	"currency": skipMissing(filterCurrency),
+
+	// Dates
+	"date": skipMissing(filterDate),
+}
+
+type filterCall struct {
+	name string
+	fn   Filter
+	args []string
+	raw  []string // Source of the arguments, E.g. "USD" with its quotes
+}
+
+// parseFilters parses a filter pipeline. E.g. | currency "USD" | upper
+// Unknown filters are parsed without a func when lenient is true
+func parseFilters(in []byte, fs Filters, lenient bool) (fcs []filterCall, err error) {
+	var (
+		words []string
+		fc    *filterCall
+	)
+
+	if words, err = splitFilterWords(in); err != nil {
+		return
+	}
+
+	for _, raw := range words {
+		w := raw
+		if raw[0] == charQuote {
+			// Quoted words were validated when split
+			w, _ = strconv.Unquote(raw)
+		}
+
+		switch {
+		case raw == "|":
+			fcs = append(fcs, filterCall{})
+			fc = &fcs[len(fcs)-1]
+		case fc == nil:
+			return nil, ErrInvalidSyntax
+		case len(fc.name) == 0:
+			if fc.fn = getFilter(w, fs); fc.fn == nil && !lenient {
+				return nil, ErrUnknownFilter
+			}
+
+			fc.name = w
+		default:
+			fc.args = append(fc.args, w)
+			fc.raw = append(fc.raw, raw)
+		}
+	}
+
+	if fc != nil && len(fc.name) == 0 {
+		return nil, ErrInvalidSyntax
+	}
+
+	return
+}
+
+// splitFilterWords splits a filter pipeline into pipes, names and arguments. Quoted words are returned
+// with their quotes, so they are not mistaken for pipes
+func splitFilterWords(in []byte) (words []string, err error) {
+	for i := 0; i < len(in); i++ {
+		switch b := in[i]; {
+		case isWhiteSpace(b):
+		case b == charPipe:
+			words = append(words, "|")
+		case b == charQuote:
+			start := i
+			for i++; i < len(in) && in[i] != charQuote; i++ {
+				if in[i] == charBackslash {
+					i++
+				}
+			}
+
+			if i >= len(in) {
+				return nil, ErrInvalidSyntax
+			}
+
+			w := string(in[start : i+1])
+			if _, err = strconv.Unquote(w); err != nil {
+				return nil, ErrInvalidSyntax
+			}
+
+			words = append(words, w)
+		default:
+			start := i
+			for i < len(in) && !isWhiteSpace(in[i]) && in[i] != charPipe && in[i] != charQuote {
+				i++
+			}
+
+			words = append(words, string(in[start:i]))
+			i--
+		}
+	}
+
+	return
+}
+
+func getFilter(name string, fs Filters) (fn Filter) {
+	if fn = fs[name]; fn != nil {
+		return
+	}
+
+	return stdFilters[name]
+}
+
+// skipMissing will return a filter which skips missing (nil) values
+func skipMissing(fn Filter) Filter {
+	return func(v interface{}, args []string, loc *Locale) (interface{}, error) {
+		if v == nil {
+			return nil, nil
+		}
+
+		return fn(v, args, loc)
+	}
+}
+
+func applyFilters(v interface{}, fcs []filterCall, loc *Locale) (out interface{}, err error) {
+	out = v
+	for _, fc := range fcs {
+		if out, err = fc.fn(out, fc.args, loc); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func filterString(v interface{}) (str string, err error) {
+	b, ok, invalid := getValueBytes(v)
+	switch {
+	case invalid:
+		err = ErrUnsupportedType
+	case ok:
+		str = string(b)
+	}
+
+	return
+}
+
+func filterFloat(v interface{}) (f float64, err error) {
+	switch nv := v.(type) {
+	case float64:
+		return nv, nil
+	case float32:
+		return float64(nv), nil
+	case int:
+		return float64(nv), nil
+	case int64:
+		return float64(nv), nil
+	case int32:
+		return float64(nv), nil
+	case uint:
+		return float64(nv), nil
+	case uint64:
+		return float64(nv), nil
+	case uint32:
+		return float64(nv), nil
+	}
+
+	var str string
+	if str, err = filterString(v); err != nil {
+		return
+	}
+
+	if f, err = strconv.ParseFloat(strings.TrimSpace(str), 64); err != nil {
+		err = ErrUnsupportedType
+	}
+
+	return
+}
+
+func filterIntArg(args []string, idx, def int) (n int, err error) {
+	if len(args) <= idx {
+		return def, nil
+	}
+
+	if n, err = strconv.Atoi(args[idx]); err != nil {
+		err = ErrInvalidFilterArgs
+	}
+
+	return
+}
+
+func filterUpper(v interface{}, args []string, loc *Locale) (interface{}, error) {
+	str, err := filterString(v)
+	return strings.ToUpper(str), err
+}
+
+func filterLower(v interface{}, args []string, loc *Locale) (interface{}, error) {
+	str, err := filterString(v)
+	return strings.ToLower(str), err
+}
+
+func filterTitle(v interface{}, args []string, loc *Locale) (interface{}, error) {
+	str, err := filterString(v)
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.Fields(str)
+	for i, w := range words {
+		r, n := utf8.DecodeRuneInString(w)
+		words[i] = strings.ToUpper(string(r)) + w[n:]
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+func filterTrim(v interface{}, args []string, loc *Locale) (interface{}, error) {
+	str, err := filterString(v)
+	return strings.TrimSpace(str), err
+}
+
+// filterTruncate truncates a value to a maximum number of characters, with an optional suffix
+// (defaults to "...") appended when a value is truncated. E.g. {{ title | truncate 40 }}
+func filterTruncate(v interface{}, args []string, loc *Locale) (out interface{}, err error) {
+	var (
+		str string
+		max int
+	)
+
+	if len(args) == 0 || len(args) > 2 {
+		return nil, ErrInvalidFilterArgs
+	}
+
+	if max, err = filterIntArg(args, 0, 0); err != nil {
+		return
+	} else if max < 0 {
+		return nil, ErrInvalidFilterArgs
+	}
+
+	if str, err = filterString(v); err != nil {
+		return
+	}
+
+	if utf8.RuneCountInString(str) <= max {
+		return str, nil
+	}
+
+	suffix := "..."
+	if len(args) == 2 {
+		suffix = args[1]
+	}
+
+	return string([]rune(str)[:max]) + suffix, nil
+}
+
+func filterReplace(v interface{}, args []string, loc *Locale) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, ErrInvalidFilterArgs
+	}
+
+	str, err := filterString(v)
+	return strings.Replace(str, args[0], args[1], -1), err
+}
+
+// filterDefault replaces missing and empty values. E.g. {{ nickname | default "friend" }}
+func filterDefault(v interface{}, args []string, loc *Locale) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, ErrInvalidFilterArgs
+	}
+
+	if isEmpty(v) {
+		return args[0], nil
+	}
+
+	return v, nil
+}
+
+// isEmpty will return whether or not a value is missing (nil, or a nil pointer) or has a length of 0,
+// E.g. "" or an empty list. Other values, including false and 0, are not empty
+func isEmpty(v interface{}) bool {
+	switch nv := v.(type) {
+	case nil:
+		return true
+	case string:
+		return len(nv) == 0
+	case []byte:
+		return len(nv) == 0
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+
+	return false
+}
+
+func filterURLQuery(v interface{}, args []string, loc *Locale) (interface{}, error) {
+	str, err := filterString(v)
+	return url.QueryEscape(str), err
+}
+
+// filterFixed formats a number with a fixed number of decimals (defaults to 2). E.g. {{ weight | fixed 1 }}
+func filterFixed(v interface{}, args []string, loc *Locale) (out interface{}, err error) {
+	var (
+		f    float64
+		prec int
+	)
+
+	if f, err = filterFloat(v); err != nil {
+		return
+	}
+
+	if prec, err = filterIntArg(args, 0, 2); err != nil {
+		return
+	}
+
+	return formatFloat(loc, f, prec), nil
+}
+
+// filterNumber formats a number with the digit grouping of the Locale and an optional number of decimals.
+// E.g. {{ visitors | number }} -> 1,234,567 with LocaleEnUS
+func filterNumber(v interface{}, args []string, loc *Locale) (out interface{}, err error) {
+	var (
+		f    float64
+		prec int
+	)
+
+	if f, err = filterFloat(v); err != nil {
+		return
+	}
+
+	if prec, err = filterIntArg(args, 0, -1); err != nil {
+		return
+	}
+
+	return formatFloat(loc, f, prec), nil
+}
+
+// formatFloat will format a number for the number filters. Numbers are not localized when no Locale
+// is set, as values are not (E.g. 1234.5 rather than 1,234.5)
+func formatFloat(loc *Locale, f float64, prec int) string {
+	if loc == nil {
+		return strconv.FormatFloat(f, 'f', prec, 64)
+	}
+
+	return loc.FormatFloat(f, prec)
+}
+
+// filterPercent formats a ratio as a percentage with an optional number of decimals. E.g. 0.25 -> 25%
+func filterPercent(v interface{}, args []string, loc *Locale) (out interface{}, err error) {
+	var (
+		f    float64
+		prec int
+	)
+
+	if f, err = filterFloat(v); err != nil {
+		return
+	}
+
+	if prec, err = filterIntArg(args, 0, 0); err != nil {
+		return
+	}
+
+	return formatFloat(loc, f*100, prec) + "%", nil
+}
+
+// currencies are the symbols and decimals for common ISO 4217 currency codes
+var currencies = map[string]struct {
+	symbol string
+	prec   int
+}{
+	"USD": {"$", 2},
+	"CAD": {"CA$", 2},
+	"AUD": {"A$", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"JPY": {"¥", 0},
+	"CNY": {"CN¥", 2},
+	"INR": {"₹", 2},
+}
+
+// filterCurrency formats a number as an amount of a currency (defaults to USD). E.g. {{ price | currency "EUR" }}
+func filterCurrency(v interface{}, args []string, loc *Locale) (out interface{}, err error) {
+	var f float64
+	if f, err = filterFloat(v); err != nil {
+		return
+	}
+
+	code := "USD"
+	if len(args) > 0 {
+		code = strings.ToUpper(args[0])
+	}
+
+	c, ok := currencies[code]
+	if !ok {
+		c.symbol = code + " "
+		c.prec = 2
+	}
+
+	var sign string
+	if math.Signbit(f) {
+		sign = "-"
+		f = -f
+	}
+
+	amount := formatFloat(loc, f, c.prec)
+	if loc != nil && loc.CurrencySuffix {
+		return sign + amount + " " + strings.TrimSpace(c.symbol), nil
+	}
+
+	return sign + c.symbol + amount, nil
+}
+
+// layouts are named time layouts which can be used with the date filter
+var layouts = map[string]string{
+	"date":     "2006-01-02",
+	"time":     "15:04:05",
+	"datetime": "2006-01-02 15:04:05",
+	"rfc3339":  time.RFC3339,
+	"rfc1123":  time.RFC1123,
+	"kitchen":  time.Kitchen,
+}
+
+// filterDate formats a time.Time or unix timestamp (seconds) with a named or Go layout (defaults to rfc3339).
+// The date, time and datetime named layouts are provided by the Locale when set. E.g. {{ createdAt | date "Jan 2, 2006" }}
+func filterDate(v interface{}, args []string, loc *Locale) (out interface{}, err error) {
+	var t time.Time
+	switch nv := v.(type) {
+	case time.Time:
+		t = nv
+	case *time.Time:
+		if nv == nil {
+			return
+		}
+
+		t = *nv
+	case nil:
+		return
+	default:
+		var f float64
+		if f, err = filterFloat(v); err != nil {
+			return
+		}
+
+		t = time.Unix(int64(f), 0).UTC()
+	}
+
+	layout := time.RFC3339
+	if len(args) > 0 {
+		layout = loc.layout(args[0])
+	}
+
+	return t.Format(layout), nil
+}
@@ -0,0 +1,152 @@
+package mustache
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Format will return a template with normalized tag whitespace, E.g. {{name}} and {{#  items}} are
+// formatted as {{ name }} and {{# items }}. The rendered output of the template is not changed: text,
+// including the indentation of lines, is rendered as is, so it is not modified. With WithStandaloneLines,
+// the lines of standalone section tags are not rendered, so they are indented by two spaces for each
+// section they are nested within. Partials and layouts are not loaded, and unknown filters are allowed,
+// so a template can be formatted on its own
+func Format(tmpl []byte, opts ...Option) (out []byte, err error) {
+	o := newOptions(opts)
+	o.set = NewSet()
+	o.anyFilters = true
+
+	// Standalone lines are kept, so their text can be re-indented
+	f := formatter{src: tmpl, indent: o.standalone}
+	o.standalone = false
+
+	var t *Template
+	if t, err = parseTemplate(tmpl, "", o); err != nil {
+		return
+	}
+
+	f.format(t, "", false)
+	return f.buf.Bytes(), nil
+}
+
+type formatter struct {
+	buf bytes.Buffer
+
+	src    []byte
+	indent bool // Indent the lines of standalone section tags
+	trim   bool // Trim the whitespace following a standalone tag from the next text
+}
+
+// format will write the tokens of a template. The standalone tags of nested templates are indented
+// with indent, the standalone tags of the root template keep the indentation of their line
+func (f *formatter) format(t *Template, indent string, nested bool) {
+	for _, tkn := range t.tkns {
+		switch tt := tkn.(type) {
+		case tmplToken:
+			f.text(t.tmpl[tt.start:tt.end])
+		case valToken:
+			if tt.escape {
+				f.tag("", tt.key+formatFilters(tt.filters))
+			} else {
+				f.buf.WriteString("{{{ " + tt.key + formatFilters(tt.filters) + " }}}")
+			}
+		case sectionToken:
+			key := tt.key
+			if tt.entries {
+				key = modEntries + " " + key
+			}
+
+			f.section(t.offset+tt.pos, t.offset+tt.end, "#", key, tt.t, indent, nested)
+		case invertedSectionToken:
+			f.section(t.offset+tt.pos, t.offset+tt.end, "^", tt.key, tt.t, indent, nested)
+		case messageToken:
+			if len(tt.count) > 0 {
+				f.tag("_", tt.id+" "+tt.count)
+			} else {
+				f.tag("_", tt.id)
+			}
+		case partialToken:
+			f.tag(">", tt.name)
+		case parentToken:
+			f.section(t.offset+tt.pos, t.offset+tt.end, "<", tt.name, tt.t, indent, nested)
+		case blockToken:
+			f.section(t.offset+tt.pos, t.offset+tt.end, "$", tt.name, tt.t, indent, nested)
+		}
+	}
+}
+
+func (f *formatter) text(b []byte) {
+	if f.trim && len(b) > 0 {
+		b = bytes.TrimLeft(b, " \t")
+		f.trim = false
+	}
+
+	f.buf.Write(b)
+}
+
+func (f *formatter) tag(kind, content string) {
+	f.buf.WriteString("{{" + kind + " " + content + " }}")
+}
+
+// section will write a section, whose tags span from pos to end within the source
+func (f *formatter) section(pos, end int, kind, key string, t *Template, indent string, nested bool) {
+	if !nested {
+		indent = lineIndent(f.src, pos)
+	}
+
+	// The opening tag ends at the start of the body, and the closing tag starts at its end
+	f.standalone(pos, t.offset, indent)
+	f.tag(kind, key)
+	f.format(t, indent+"  ", true)
+	f.standalone(t.offset+len(t.tmpl), end, indent)
+	f.tag("/", key)
+}
+
+// standalone will indent the line of a tag which is standalone
+func (f *formatter) standalone(pos, end int, indent string) {
+	if !f.indent {
+		return
+	}
+
+	if _, _, ok := standaloneLine(f.src, pos, end); !ok {
+		return
+	}
+
+	// The line only holds whitespace before the tag
+	b := f.buf.Bytes()
+	n := len(b)
+	for n > 0 && isBlank(b[n-1]) {
+		n--
+	}
+
+	f.buf.Truncate(n)
+	f.buf.WriteString(indent)
+	f.trim = true
+}
+
+// lineIndent will return the whitespace which the line of pos starts with
+func lineIndent(src []byte, pos int) string {
+	start := bytes.LastIndexByte(src[:pos], charNewline) + 1
+	end := start
+	for end < pos && isBlank(src[end]) {
+		end++
+	}
+
+	return string(src[start:end])
+}
+
+// formatFilters will return a filter pipeline, E.g. | truncate 40 "..." | upper
+// Arguments are written as they are quoted within the template
+func formatFilters(fcs []filterCall) string {
+	var sb strings.Builder
+	for _, fc := range fcs {
+		sb.WriteString(" | ")
+		sb.WriteString(fc.name)
+		for _, arg := range fc.raw {
+			sb.WriteByte(' ')
+			sb.WriteString(arg)
+		}
+	}
+
+	return sb.String()
+}
@@ -1,6 +1,12 @@
 package mustache
 
-import "strconv"
+import (
+	"encoding"
+	"fmt"
+	"iter"
+	"reflect"
+	"strconv"
+)
 
 // Aficionado is someone who really appreciates the Mustache
 type Aficionado interface {
@@ -12,13 +18,26 @@ type StringMap map[string]string
 
 // MarshalMustache is what makes us one of the best, baby!
 func (m StringMap) MarshalMustache(r *Renderer) (err error) {
-	r.ForEach(m.Get)
-	return
+	return r.forEach(m)
 }
 
 // Get will get a value by key
 func (m StringMap) Get(key string) (val interface{}) {
-	return []byte(m[key])
+	if v, ok := m[key]; ok {
+		val = []byte(v)
+	}
+
+	return
+}
+
+// appendValue will append a value to dst without boxing it as an interface{}
+func (m StringMap) appendValue(dst []byte, key string) (b []byte, ok bool) {
+	var v string
+	if v, ok = m[key]; ok {
+		b = append(dst, v...)
+	}
+
+	return
 }
 
 // InterfaceMap is a common map[string]string, has the func needed to be an Aficionado
@@ -26,8 +45,7 @@ type InterfaceMap map[string]interface{}
 
 // MarshalMustache is what makes us one of the best, baby!
 func (m InterfaceMap) MarshalMustache(r *Renderer) (err error) {
-	r.ForEach(m.Get)
-	return
+	return r.forEach(m)
 }
 
 // Get will get a value by key
@@ -40,13 +58,26 @@ type BytesMap map[string][]byte
 
 // MarshalMustache is what makes us one of the best, baby!
 func (m BytesMap) MarshalMustache(r *Renderer) (err error) {
-	r.ForEach(m.Get)
-	return
+	return r.forEach(m)
 }
 
 // Get will get a value by key
 func (m BytesMap) Get(key string) (val interface{}) {
-	return m[key]
+	if v, ok := m[key]; ok {
+		val = v
+	}
+
+	return
+}
+
+// appendValue will append a value to dst without boxing it as an interface{}
+func (m BytesMap) appendValue(dst []byte, key string) (b []byte, ok bool) {
+	var v []byte
+	if v, ok = m[key]; ok {
+		b = append(dst, v...)
+	}
+
+	return
 }
 
 type Value struct {
@@ -54,298 +85,361 @@ type Value struct {
 }
 
 func (val Value) MarshalMustache(r *Renderer) (err error) {
-	return r.ForEach(func(k string) (v interface{}) {
-		switch k {
-		case ".":
-			v = val.v
-		}
-		return
-	})
+	return r.forEach(val)
 }
 
+// Get will get a value by key, the value is only referenced by .
+func (val Value) Get(key string) (v interface{}) {
+	if key == "." {
+		v = val.v
+	}
+
+	return
+}
+
+// getValueBytes will return the bytes of a value. []byte values are returned as is
 func getValueBytes(v interface{}) (b []byte, ok, invalid bool) {
+	if nv, isBytes := v.([]byte); isBytes {
+		return nv, true, false
+	}
+
+	return appendValueBytes(nil, v)
+}
+
+// appendValueBytes will append the bytes of a value to dst, so rendering common values does not allocate
+func appendValueBytes(dst []byte, v interface{}) (b []byte, ok, invalid bool) {
 	ok = true
 	switch nv := v.(type) {
 	case []byte:
-		b = nv
+		b = append(dst, nv...)
 	case string:
-		b = []byte(nv)
+		b = append(dst, nv...)
 
 	case int64:
-		b = strconv.AppendInt(b, nv, 10)
+		b = strconv.AppendInt(dst, nv, 10)
 	case int32:
-		b = strconv.AppendInt(b, int64(nv), 10)
+		b = strconv.AppendInt(dst, int64(nv), 10)
 	case int16:
-		b = strconv.AppendInt(b, int64(nv), 10)
+		b = strconv.AppendInt(dst, int64(nv), 10)
 	case int8:
-		b = strconv.AppendInt(b, int64(nv), 10)
+		b = strconv.AppendInt(dst, int64(nv), 10)
 	case int:
-		b = strconv.AppendInt(b, int64(nv), 10)
+		b = strconv.AppendInt(dst, int64(nv), 10)
 
 	case uint64:
-		b = strconv.AppendUint(b, nv, 10)
+		b = strconv.AppendUint(dst, nv, 10)
 	case uint32:
-		b = strconv.AppendUint(b, uint64(nv), 10)
+		b = strconv.AppendUint(dst, uint64(nv), 10)
 	case uint16:
-		b = strconv.AppendUint(b, uint64(nv), 10)
+		b = strconv.AppendUint(dst, uint64(nv), 10)
 	case uint8:
-		b = strconv.AppendUint(b, uint64(nv), 10)
+		b = strconv.AppendUint(dst, uint64(nv), 10)
 	case uint:
-		b = strconv.AppendUint(b, uint64(nv), 10)
+		b = strconv.AppendUint(dst, uint64(nv), 10)
 
 	case float64:
-		b = strconv.AppendFloat(b, nv, 'f', -1, 64)
+		b = strconv.AppendFloat(dst, nv, 'f', -1, 64)
 	case float32:
-		b = strconv.AppendFloat(b, float64(nv), 'f', -1, 32)
+		b = strconv.AppendFloat(dst, float64(nv), 'f', -1, 32)
 
 	case bool:
-		b = strconv.AppendBool(b, nv)
+		b = strconv.AppendBool(dst, nv)
 	case nil:
 		ok = false
 
 	default:
-		ok = false
-		invalid = true
+		b, ok, invalid = appendOtherValueBytes(dst, v)
 	}
 
 	return
 }
 
-func getAficionado(pa Aficionado, v interface{}) (a Aficionado, ok, invalid bool) {
+// appendOtherValueBytes handles values which are not builtin scalars. In order of precedence:
+//   - encoding.TextMarshaler
+//   - fmt.Stringer
+//   - error
+//   - Pointers (dereferenced)
+//   - Named types whose underlying type is a builtin scalar
+func appendOtherValueBytes(dst []byte, v interface{}) (b []byte, ok, invalid bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return
+	}
+
 	ok = true
 	switch nv := v.(type) {
-	case Aficionado:
-		a = nv
-	case bool:
-		if nv {
-			a = pa
+	case encoding.TextMarshaler:
+		var (
+			text []byte
+			err  error
+		)
+
+		if text, err = nv.MarshalText(); err != nil {
+			ok = false
+			invalid = true
+			break
 		}
-	case map[string]string:
-		a = StringMap(nv)
-	case map[string]interface{}:
-		a = InterfaceMap(nv)
-	case map[string][]byte:
-		a = BytesMap(nv)
 
-	case nil:
-		ok = false
+		b = append(dst, text...)
+	case fmt.Stringer:
+		b = append(dst, nv.String()...)
+	case error:
+		b = append(dst, nv.Error()...)
 
 	default:
-		ok = false
-		invalid = true
+		if rv.Kind() == reflect.Ptr {
+			return appendValueBytes(dst, rv.Elem().Interface())
+		}
+
+		b, ok, invalid = appendReflectValueBytes(dst, rv)
 	}
 
 	return
 }
 
-func getSection(pa Aficionado, v interface{}) (s section, ok, invalid bool) {
-	if v == nil {
-		return
-	}
-
-	var a Aficionado
-	if a, ok, _ = getAficionado(pa, v); ok {
-		s = a
-		return
-	}
-
+// appendReflectValueBytes handles named types whose underlying type is a builtin scalar
+func appendReflectValueBytes(dst []byte, rv reflect.Value) (b []byte, ok, invalid bool) {
 	ok = true
-	switch nv := v.(type) {
-	case []Aficionado:
-		s = nv
-	case []interface{}:
-		as := make([]Aficionado, len(nv))
-		for k, v := range nv {
-			if a, ok, invalid = getAficionado(pa, v); !ok || invalid {
-				return
-			}
-
-			as[k] = a
+	switch rv.Kind() {
+	case reflect.String:
+		b = append(dst, rv.String()...)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b = strconv.AppendInt(dst, rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b = strconv.AppendUint(dst, rv.Uint(), 10)
+	case reflect.Float32:
+		b = strconv.AppendFloat(dst, rv.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		b = strconv.AppendFloat(dst, rv.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		b = strconv.AppendBool(dst, rv.Bool())
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			ok = false
+			invalid = true
+			break
 		}
 
-		s = as
-	case []byte:
-		s = ByteSlice(nv).Values()
-	case []string:
-		s = StringSlice(nv).Values()
-	case []int64:
-		s = Int64Slice(nv).Values()
-	case []int32:
-		s = Int32Slice(nv).Values()
-	case []int:
-		s = IntSlice(nv).Values()
-	case []float64:
-		s = Float64Slice(nv).Values()
-	case []float32:
-		s = Float32Slice(nv).Values()
-
-	case nil:
-		ok = false
+		b = append(dst, rv.Bytes()...)
 
 	default:
 		ok = false
 		invalid = true
 	}
 
-	return
-
-}
-
-type ByteSlice []byte
-
-func (s ByteSlice) Values() (as []Aficionado) {
-	as = make([]Aficionado, len(s))
-	for k, v := range s {
-		as[k] = Value{v}
-	}
-
-	return
-}
-
-type StringSlice []string
-
-func (s StringSlice) Values() (as []Aficionado) {
-	as = make([]Aficionado, len(s))
-	for k, v := range s {
-		as[k] = Value{v}
-	}
-
-	return
-}
-
-type Int64Slice []int64
-
-func (s Int64Slice) Values() (as []Aficionado) {
-	as = make([]Aficionado, len(s))
-	for k, v := range s {
-		as[k] = Value{v}
-	}
-
 	return
 }
 
-type Int32Slice []int32
-
-func (s Int32Slice) Values() (as []Aficionado) {
-	as = make([]Aficionado, len(s))
-	for k, v := range s {
-		as[k] = Value{v}
-	}
-
-	return
-}
-
-type IntSlice []int
-
-func (s IntSlice) Values() (as []Aficionado) {
-	as = make([]Aficionado, len(s))
-	for k, v := range s {
-		as[k] = Value{v}
+// getAficionado will return an Aficionado for a list item. Items which are not
+// Aficionados or maps are wrapped as a Value, so they can be referenced with {{ . }}
+func getAficionado(v interface{}) (a Aficionado) {
+	switch nv := v.(type) {
+	case Aficionado:
+		return nv
+	case map[string]string:
+		return StringMap(nv)
+	case map[string]interface{}:
+		return InterfaceMap(nv)
+	case map[string][]byte:
+		return BytesMap(nv)
 	}
 
-	return
-}
-
-type Float64Slice []float64
-
-func (s Float64Slice) Values() (as []Aficionado) {
-	as = make([]Aficionado, len(s))
-	for k, v := range s {
-		as[k] = Value{v}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
 	}
 
-	return
-}
-
-type Float32Slice []float32
-
-func (s Float32Slice) Values() (as []Aficionado) {
-	as = make([]Aficionado, len(s))
-	for k, v := range s {
-		as[k] = Value{v}
+	if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String {
+		return reflectMap{rv}
 	}
 
-	return
+	return Value{v}
 }
 
-func getInvertedSection(pa Aficionado, v interface{}) (s section, ok, invalid bool) {
+// getSection will return the section for a value. Sections and inverted sections share
+// the same notion of truthiness: nil, false, empty strings, empty maps and empty lists are falsey.
+// Falsey values return ok as false
+func getSection(pa Aficionado, v interface{}) (s section, ok, invalid bool) {
 	switch nv := v.(type) {
+	case nil:
 	case bool:
-		if !nv {
-			ok = true
+		if nv {
 			s = pa
 		}
+
 	case string:
-		if len(nv) == 0 {
-			ok = true
-			s = pa
+		if len(nv) > 0 {
+			s = Value{nv}
+		}
+	case []byte:
+		if len(nv) > 0 {
+			s = Value{nv}
 		}
 
+	case StringMap:
+		if len(nv) > 0 {
+			s = nv
+		}
+	case InterfaceMap:
+		if len(nv) > 0 {
+			s = nv
+		}
+	case BytesMap:
+		if len(nv) > 0 {
+			s = nv
+		}
 	case map[string]string:
-		if len(nv) == 0 {
-			ok = true
-			s = pa
+		if len(nv) > 0 {
+			s = StringMap(nv)
 		}
 	case map[string]interface{}:
-		if len(nv) == 0 {
-			ok = true
-			s = pa
+		if len(nv) > 0 {
+			s = InterfaceMap(nv)
 		}
 	case map[string][]byte:
-		if len(nv) == 0 {
-			ok = true
-			s = pa
+		if len(nv) > 0 {
+			s = BytesMap(nv)
 		}
+	case Aficionado:
+		s = nv
 
+	case Iterator:
+		s = iteratorSequence(nv)
+	case iter.Seq[Aficionado]:
+		if nv != nil {
+			s = seqSequence(nv)
+		}
+	case <-chan Aficionado:
+		if nv != nil {
+			s = aficionadoChanSequence(nv)
+		}
+	case chan Aficionado:
+		if nv != nil {
+			s = aficionadoChanSequence(nv)
+		}
+
+	// Lists are rendered in place by processList, so they are returned as is rather than being
+	// copied into a []Aficionado
+	case []Aficionado:
+		if len(nv) > 0 {
+			s = v
+		}
+	case []interface{}:
+		if len(nv) > 0 {
+			s = v
+		}
+	case []map[string]interface{}:
+		if len(nv) > 0 {
+			s = v
+		}
+	case []map[string]string:
+		if len(nv) > 0 {
+			s = v
+		}
 	case []string:
-		if len(nv) == 0 {
-			s = pa
-			ok = true
+		if len(nv) > 0 {
+			s = v
 		}
 	case []int64:
-		if len(nv) == 0 {
-			s = pa
-			ok = true
+		if len(nv) > 0 {
+			s = v
 		}
 	case []int32:
-		if len(nv) == 0 {
-			s = pa
-			ok = true
+		if len(nv) > 0 {
+			s = v
 		}
 	case []int:
-		if len(nv) == 0 {
-			s = pa
-			ok = true
+		if len(nv) > 0 {
+			s = v
 		}
 	case []float64:
-		if len(nv) == 0 {
-			s = pa
-			ok = true
+		if len(nv) > 0 {
+			s = v
 		}
 	case []float32:
-		if len(nv) == 0 {
-			s = pa
-			ok = true
+		if len(nv) > 0 {
+			s = v
 		}
-	case []byte:
-		if len(nv) == 0 {
-			s = pa
-			ok = true
+
+	default:
+		s, invalid = getReflectSection(pa, reflect.ValueOf(v))
+	}
+
+	ok = s != nil
+	return
+}
+
+// getReflectSection handles the values which are not covered by the common types
+func getReflectSection(pa Aficionado, rv reflect.Value) (s section, invalid bool) {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return
 		}
-	case []interface{}:
-		if len(nv) == 0 {
-			s = pa
-			ok = true
+
+		var ok bool
+		s, ok, invalid = getSection(pa, rv.Elem().Interface())
+		if !ok {
+			s = nil
 		}
-	case []Aficionado:
-		if len(nv) == 0 {
+
+	case reflect.Bool:
+		if rv.Bool() {
 			s = pa
-			ok = true
 		}
 
-	case Aficionado:
-	case nil:
-		ok = true
+	case reflect.String:
+		if rv.Len() > 0 {
+			s = Value{rv.Interface()}
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		s = Value{rv.Interface()}
+
+	case reflect.Slice, reflect.Array:
+		switch {
+		case rv.Len() == 0:
+		case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+			// Byte slices are values, as []byte is
+			s = Value{rv.Interface()}
+		default:
+			s = reflectList{rv}
+		}
+
+	case reflect.Chan:
+		if rv.Type().ChanDir()&reflect.RecvDir == 0 {
+			invalid = true
+			return
+		}
+
+		if !rv.IsNil() {
+			s = chanSequence(rv)
+		}
+
+	case reflect.Func:
+		if rv.IsNil() {
+			return
+		}
+
+		var seq sequence
+		if seq = funcSequence(rv); seq == nil {
+			invalid = true
+			return
+		}
+
+		s = seq
+
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			invalid = true
+			return
+		}
+
+		if rv.Len() > 0 {
+			s = reflectMap{rv}
+		}
 
 	default:
 		invalid = true
@@ -354,10 +448,66 @@ func getInvertedSection(pa Aficionado, v interface{}) (s section, ok, invalid bo
 	return
 }
 
+// reflectMap is an Aficionado for string-keyed maps which are not covered by the common map types
+type reflectMap struct {
+	rv reflect.Value
+}
+
+// MarshalMustache is what makes us one of the best, baby!
+func (m reflectMap) MarshalMustache(r *Renderer) (err error) {
+	return r.forEach(m)
+}
+
+// Get will get a value by key
+func (m reflectMap) Get(key string) (val interface{}) {
+	kv := reflect.ValueOf(key).Convert(m.rv.Type().Key())
+	if v := m.rv.MapIndex(kv); v.IsValid() {
+		val = v.Interface()
+	}
+
+	return
+}
+
+// reflectList is a list which is not covered by the common list types, its items are indexed by reflection
+type reflectList struct {
+	rv reflect.Value
+}
+
+// getInvertedSection will return the parent Aficionado when a value is falsey
+func getInvertedSection(pa Aficionado, v interface{}) (s section, ok, invalid bool) {
+	var truthy bool
+	if _, truthy, invalid = getSection(pa, v); invalid || truthy {
+		return
+	}
+
+	s = pa
+	ok = true
+	return
+}
+
 func isChar(b byte) bool {
 	return (b >= lwrCaseStart && b <= lwrCaseEnd) || (b >= uprCaseStart && b <= uprCaseEnd)
 }
 
+// isNameChar returns whether or not a byte is valid within a partial, layout or closing tag name
+func isNameChar(b byte) bool {
+	return isChar(b) || (b >= digitStart && b <= digitEnd) ||
+		b == charPeriod || b == charFSlash || b == charUnderscore || b == charHyphen
+}
+
 func isWhiteSpace(b byte) bool {
 	return b == charSpace || b == charNewline || b == charTab
 }
+
+// needsEscape will return whether or not a value contains characters which are escaped within HTML.
+// Values without them are written as is, as escaping would allocate a copy
+func needsEscape(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case '<', '>', '&', '\'', '"':
+			return true
+		}
+	}
+
+	return false
+}
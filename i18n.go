@@ -0,0 +1,105 @@
+package mustache
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/missionMeteora/toolkit/errors"
+)
+
+const (
+	// ErrNoCatalog is returned when a message tag is rendered without a Catalog
+	ErrNoCatalog = errors.Error("no catalog has been set")
+
+	// ErrMissingMessage is returned when a message cannot be found and OnMissingError is set
+	ErrMissingMessage = errors.Error("missing message")
+)
+
+// Catalog provides translated messages for message tags (E.g. {{_ greeting.welcome }}).
+// Messages are templates, so they can reference the current context (E.g. "Welcome, {{ name }}!")
+type Catalog interface {
+	// Message returns the message for an id in a locale (E.g. "de-DE", empty when no Locale has been set).
+	// n is the count used to select a plural form, and is -1 for messages which are not pluralized
+	Message(locale, id string, n int) (msg string, ok bool)
+}
+
+// WithCatalog will set the Catalog used for message tags
+func WithCatalog(c Catalog) Option {
+	return func(o *options) {
+		o.catalog = c
+	}
+}
+
+// MessageCatalog is a Catalog of messages by locale, then message id. Locales fall back to their
+// base language (E.g. "de-AT" -> "de"), and then to the empty locale.
+// Plural forms are separated by a pipe and selected by count:
+//   - "one|other": one is used when n is 1
+//   - "zero|one|other": zero is used when n is 0, one is used when n is 1
+type MessageCatalog map[string]map[string]string
+
+// Message returns the message for an id in a locale
+func (c MessageCatalog) Message(locale, id string, n int) (msg string, ok bool) {
+	for {
+		if msg, ok = c[locale][id]; ok {
+			return pluralForm(msg, n), true
+		}
+
+		if len(locale) == 0 {
+			return
+		}
+
+		if idx := strings.LastIndexAny(locale, "-_"); idx > -1 {
+			locale = locale[:idx]
+		} else {
+			locale = ""
+		}
+	}
+}
+
+func pluralForm(msg string, n int) string {
+	if n < 0 {
+		return msg
+	}
+
+	forms := strings.Split(msg, "|")
+	switch {
+	case len(forms) == 2 && n == 1:
+		return forms[0]
+	case len(forms) == 2:
+		return forms[1]
+	case len(forms) == 3 && n < 2:
+		return forms[n]
+	case len(forms) == 3:
+		return forms[2]
+	}
+
+	return msg
+}
+
+// messageCache holds the parsed templates of translated messages
+type messageCache struct {
+	mux sync.RWMutex
+	m   map[string]*Template
+}
+
+func (mc *messageCache) get(msg string, o *options) (t *Template, err error) {
+	mc.mux.RLock()
+	t = mc.m[msg]
+	mc.mux.RUnlock()
+	if t != nil {
+		return
+	}
+
+	if t, err = parseNested([]byte(msg), "", o, nesting{message: true}); err != nil {
+		return
+	}
+
+	mc.mux.Lock()
+	if mc.m == nil {
+		mc.m = make(map[string]*Template)
+	}
+
+	mc.m[msg] = t
+	mc.mux.Unlock()
+	return
+}
@@ -0,0 +1,98 @@
+package mustache
+
+import (
+	"iter"
+	"reflect"
+)
+
+// Iterator allows sections to be streamed without materializing a list
+type Iterator interface {
+	Next() (a Aficionado, ok bool)
+}
+
+// sequence is a section which is rendered as it is iterated. Iterators, channels and
+// iter.Seq/iter.Seq2 funcs are all converted to a sequence. Channel sequences stop once done
+// is closed (E.g. when the context of a render is done), done is nil when a render cannot be cancelled.
+// Note: Sequences cannot be checked for emptiness without being consumed, so they are always truthy
+type sequence func(done <-chan struct{}, yield func(Aficionado) bool)
+
+func iteratorSequence(it Iterator) sequence {
+	return func(_ <-chan struct{}, yield func(Aficionado) bool) {
+		for {
+			a, ok := it.Next()
+			if !ok || !yield(a) {
+				return
+			}
+		}
+	}
+}
+
+func seqSequence(seq iter.Seq[Aficionado]) sequence {
+	return func(_ <-chan struct{}, yield func(Aficionado) bool) {
+		seq(yield)
+	}
+}
+
+func aficionadoChanSequence(ch <-chan Aficionado) sequence {
+	return func(done <-chan struct{}, yield func(Aficionado) bool) {
+		for {
+			select {
+			case a, ok := <-ch:
+				if !ok || !yield(a) {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+func chanSequence(rv reflect.Value) sequence {
+	return func(done <-chan struct{}, yield func(Aficionado) bool) {
+		cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: rv}}
+		if done != nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)})
+		}
+
+		for {
+			chosen, v, ok := reflect.Select(cases)
+			if chosen > 0 || !ok || !yield(getAficionado(v.Interface())) {
+				return
+			}
+		}
+	}
+}
+
+// funcSequence will convert a func matching iter.Seq or iter.Seq2 into a sequence.
+// Seq2 funcs use their second value (E.g. the value of a key/value pair) as the context.
+// A nil sequence is returned when the func does not match
+func funcSequence(rv reflect.Value) sequence {
+	t := rv.Type()
+	if t.NumIn() != 1 || t.NumOut() != 0 {
+		return nil
+	}
+
+	yt := t.In(0)
+	if yt.Kind() != reflect.Func || yt.NumOut() != 1 || yt.Out(0).Kind() != reflect.Bool {
+		return nil
+	}
+
+	var idx int
+	switch yt.NumIn() {
+	case 1:
+	case 2:
+		idx = 1
+	default:
+		return nil
+	}
+
+	return func(_ <-chan struct{}, yield func(Aficionado) bool) {
+		fn := reflect.MakeFunc(yt, func(args []reflect.Value) []reflect.Value {
+			ok := yield(getAficionado(args[idx].Interface()))
+			return []reflect.Value{reflect.ValueOf(ok).Convert(yt.Out(0))}
+		})
+
+		rv.Call([]reflect.Value{fn})
+	}
+}
@@ -0,0 +1,73 @@
+package mustache
+
+import "sync"
+
+// noKey is the ID of tokens without a key of the data, E.g. {{# . }} and {{ @index }}
+const noKey = -1
+
+// IndexedAficionado is an Aficionado which gets values by the IDs of their keys rather than by key.
+// Keys are assigned IDs as templates are parsed (See Template.Keys), so values can be resolved by
+// indexing an array rather than by comparing strings. GetByID is never called with an ID outside of
+// Keys, the current context (E.g. {{ . }}) and keys which are only used by Catalog messages have no
+// ID and are missing. MarshalMustache is not called when an IndexedAficionado is rendered
+type IndexedAficionado interface {
+	Aficionado
+	GetByID(id int) interface{}
+}
+
+// keyTable interns the keys of templates as IDs. A table is shared by a Template, its sub-templates
+// and partials, and by all of the templates of a Set, so the IDs of keys are the same for each
+type keyTable struct {
+	mux  sync.RWMutex
+	ids  map[string]int
+	keys []string
+}
+
+func newKeyTable() *keyTable {
+	return &keyTable{ids: make(map[string]int)}
+}
+
+// intern will return the ID of a key, assigning the next ID when the key is new. Reserved keys
+// (prefixed with @) and the current context are resolved by the Renderer, so they are not interned
+func (kt *keyTable) intern(key string) (id int) {
+	if len(key) == 0 || key == "." || key[0] == charAt {
+		return noKey
+	}
+
+	kt.mux.Lock()
+	defer kt.mux.Unlock()
+	var ok bool
+	if id, ok = kt.ids[key]; !ok {
+		id = len(kt.keys)
+		kt.ids[key] = id
+		kt.keys = append(kt.keys, key)
+	}
+
+	return
+}
+
+func (kt *keyTable) id(key string) (id int, ok bool) {
+	kt.mux.RLock()
+	id, ok = kt.ids[key]
+	kt.mux.RUnlock()
+	return
+}
+
+func (kt *keyTable) list() (keys []string) {
+	kt.mux.RLock()
+	keys = append(keys, kt.keys...)
+	kt.mux.RUnlock()
+	return
+}
+
+// KeyID will return the ID of a key of the Template, for the GetByID func of an IndexedAficionado
+func (t *Template) KeyID(key string) (id int, ok bool) {
+	return t.o.keys.id(key)
+}
+
+// Keys will return the keys of the Template by ID, E.g. Keys()[id]. IDs are assigned from 0 in the
+// order keys are parsed, and are shared with the partials of the Template and the templates of its
+// Set. IDs are never reassigned, so keys are only appended as templates are added to a Set
+func (t *Template) Keys() []string {
+	return t.o.keys.list()
+}
@@ -0,0 +1,74 @@
+package mustache
+
+import "github.com/missionMeteora/toolkit/errors"
+
+const (
+	// ErrSourceTooLarge is returned when a template source exceeds Limits.MaxSourceBytes
+	ErrSourceTooLarge = errors.Error("template source exceeds limit")
+
+	// ErrOutputTooLarge is returned when a render exceeds Limits.MaxOutputBytes
+	ErrOutputTooLarge = errors.Error("output exceeds limit")
+
+	// ErrDepthExceeded is returned when sections are nested deeper than Limits.MaxDepth
+	ErrDepthExceeded = errors.Error("section depth exceeds limit")
+
+	// ErrPartialDepthExceeded is returned when partials are nested deeper than Limits.MaxPartialDepth
+	ErrPartialDepthExceeded = errors.Error("partial depth exceeds limit")
+
+	// ErrIterationsExceeded is returned when a render exceeds Limits.MaxIterations
+	ErrIterationsExceeded = errors.Error("iterations exceed limit")
+)
+
+// WithLimits will set the Limits enforced when parsing and rendering a Template
+func WithLimits(l Limits) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
+
+// defaultMaxPartialDepth is the nesting depth of partials and layouts when no MaxPartialDepth is set, so
+// a partial which includes itself returns ErrPartialDepthExceeded rather than overflowing the stack
+const defaultMaxPartialDepth = 1000
+
+// Limits guard against untrusted templates and data. A zero value is unlimited, other than the nesting
+// depth of partials
+type Limits struct {
+	// MaxSourceBytes is the maximum size of a template source, including partials loaded from files
+	MaxSourceBytes int
+	// MaxOutputBytes is the maximum size of the output of a render
+	MaxOutputBytes int
+
+	// MaxDepth is the maximum nesting depth of sections. Partials and layouts count as a level of nesting
+	MaxDepth int
+	// MaxPartialDepth is the maximum nesting depth of partials and layouts, 1000 when zero
+	MaxPartialDepth int
+
+	// MaxIterations is the maximum number of section iterations of a render, counted across every section
+	MaxIterations int
+}
+
+// checkParse will check the limits of a (sub-)template being parsed
+func (l *Limits) checkParse(size, depth, partials int) error {
+	switch {
+	case exceeds(l.MaxSourceBytes, size):
+		return ErrSourceTooLarge
+	case exceeds(l.MaxDepth, depth):
+		return ErrDepthExceeded
+	case exceeds(l.maxPartialDepth(), partials):
+		return ErrPartialDepthExceeded
+	}
+
+	return nil
+}
+
+func (l *Limits) maxPartialDepth() int {
+	if l.MaxPartialDepth == 0 {
+		return defaultMaxPartialDepth
+	}
+
+	return l.MaxPartialDepth
+}
+
+func exceeds(max, n int) bool {
+	return max > 0 && n > max
+}
@@ -0,0 +1,204 @@
+package mustache
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	// LocaleEnUS is the Locale for English (United States)
+	LocaleEnUS = &Locale{
+		Name:           "en-US",
+		Decimal:        ".",
+		Group:          ",",
+		Precision:      -1,
+		DateLayout:     "01/02/2006",
+		TimeLayout:     "3:04 PM",
+		DateTimeLayout: "01/02/2006 3:04 PM",
+	}
+
+	// LocaleEnGB is the Locale for English (United Kingdom)
+	LocaleEnGB = &Locale{
+		Name:           "en-GB",
+		Decimal:        ".",
+		Group:          ",",
+		Precision:      -1,
+		DateLayout:     "02/01/2006",
+		TimeLayout:     "15:04",
+		DateTimeLayout: "02/01/2006 15:04",
+	}
+
+	// LocaleDeDE is the Locale for German (Germany)
+	LocaleDeDE = &Locale{
+		Name:           "de-DE",
+		Decimal:        ",",
+		Group:          ".",
+		Precision:      -1,
+		DateLayout:     "02.01.2006",
+		TimeLayout:     "15:04",
+		DateTimeLayout: "02.01.2006 15:04",
+		CurrencySuffix: true,
+	}
+
+	// LocaleFrFR is the Locale for French (France)
+	LocaleFrFR = &Locale{
+		Name:           "fr-FR",
+		Decimal:        ",",
+		Group:          " ",
+		Precision:      -1,
+		DateLayout:     "02/01/2006",
+		TimeLayout:     "15:04",
+		DateTimeLayout: "02/01/2006 15:04",
+		CurrencySuffix: true,
+	}
+
+	// LocaleJaJP is the Locale for Japanese (Japan)
+	LocaleJaJP = &Locale{
+		Name:           "ja-JP",
+		Decimal:        ".",
+		Group:          ",",
+		Precision:      -1,
+		DateLayout:     "2006/01/02",
+		TimeLayout:     "15:04",
+		DateTimeLayout: "2006/01/02 15:04",
+	}
+)
+
+// WithLocale will set the default Locale used when rendering a Template
+func WithLocale(loc *Locale) Option {
+	return func(o *options) {
+		o.locale = loc
+	}
+}
+
+// Locale controls how floats and time values are rendered. Integers are not grouped when rendered
+// directly, as they are commonly identifiers or years. Use the number filter to group them
+type Locale struct {
+	// Name is the language tag of the Locale, E.g. en-US
+	Name string
+
+	// Decimal is the decimal separator
+	Decimal string
+	// Group is the digit grouping (thousands) separator
+	Group string
+	// Precision is the number of decimals floats are rendered with, -1 will use the fewest decimals necessary
+	Precision int
+
+	// DateLayout, TimeLayout and DateTimeLayout are the layouts for the date filter's "date", "time" and
+	// "datetime" named layouts. Time values are rendered with DateTimeLayout
+	DateLayout     string
+	TimeLayout     string
+	DateTimeLayout string
+
+	// CurrencySuffix will place currency symbols after the amount, E.g. 1.234,50 €
+	CurrencySuffix bool
+}
+
+// FormatFloat will format a float with the provided number of decimals (-1 uses the fewest decimals necessary)
+func (l *Locale) FormatFloat(f float64, prec int) string {
+	return string(l.appendFloat(nil, f, prec, 64))
+}
+
+// FormatInt will format an integer with digit grouping
+func (l *Locale) FormatInt(i int64) string {
+	return string(l.appendInt(nil, i))
+}
+
+// FormatTime will format a time value with the Locale's DateTimeLayout
+func (l *Locale) FormatTime(t time.Time) string {
+	return t.Format(l.layout("datetime"))
+}
+
+func (l *Locale) appendInt(b []byte, i int64) []byte {
+	if i < 0 {
+		b = append(b, '-')
+	}
+
+	// Note: uint64 conversion is used so math.MinInt64 is handled properly
+	u := uint64(i)
+	if i < 0 {
+		u = -u
+	}
+
+	return l.appendGrouped(b, strconv.AppendUint(nil, u, 10))
+}
+
+func (l *Locale) appendFloat(b []byte, f float64, prec, bitSize int) []byte {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.AppendFloat(b, f, 'f', prec, bitSize)
+	}
+
+	if math.Signbit(f) && f != 0 {
+		b = append(b, '-')
+		f = -f
+	}
+
+	num := strconv.AppendFloat(nil, f, 'f', prec, bitSize)
+	whole, frac := num, []byte(nil)
+	if idx := bytes.IndexByte(num, charPeriod); idx > -1 {
+		whole, frac = num[:idx], num[idx+1:]
+	}
+
+	b = l.appendGrouped(b, whole)
+	if len(frac) > 0 {
+		b = append(b, l.Decimal...)
+		b = append(b, frac...)
+	}
+
+	return b
+}
+
+// appendGrouped will append digits with the group separator between every three digits
+func (l *Locale) appendGrouped(b, digits []byte) []byte {
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b = append(b, l.Group...)
+		}
+
+		b = append(b, d)
+	}
+
+	return b
+}
+
+// layout will return the layout for a named layout, the name is returned when it is not a named layout
+func (l *Locale) layout(name string) string {
+	if l != nil {
+		switch name {
+		case "date":
+			if len(l.DateLayout) > 0 {
+				return l.DateLayout
+			}
+		case "time":
+			if len(l.TimeLayout) > 0 {
+				return l.TimeLayout
+			}
+		case "datetime":
+			if len(l.DateTimeLayout) > 0 {
+				return l.DateTimeLayout
+			}
+		}
+	}
+
+	if layout, ok := layouts[name]; ok {
+		return layout
+	}
+
+	return name
+}
+
+// appendLocaleValueBytes will append the values which are affected by a Locale to dst
+func appendLocaleValueBytes(dst []byte, l *Locale, v interface{}) (b []byte, ok bool) {
+	switch nv := v.(type) {
+	case float64:
+		return l.appendFloat(dst, nv, l.Precision, 64), true
+	case float32:
+		return l.appendFloat(dst, float64(nv), l.Precision, 32), true
+	case time.Time:
+		return append(dst, l.FormatTime(nv)...), true
+	}
+
+	return
+}
@@ -0,0 +1,51 @@
+package mustache
+
+// loop holds the metadata for the current iteration of a section. The metadata is
+// resolved through reserved keys, so it never shadows the keys of the user's data:
+//   - @index: 0-based position
+//   - @number: 1-based position
+//   - @first: true for the first iteration
+//   - @last: true for the final iteration
+//   - @odd, @even: parity of @index
+//   - @length: number of iterations (unavailable for streamed sections)
+//   - @key, @value: the current pair when iterating map entries
+type loop struct {
+	index  int
+	length int // -1 when the length is unknown
+	last   bool
+
+	entry bool
+	key   string
+	value interface{}
+}
+
+func (l *loop) get(key string) (v interface{}) {
+	switch key {
+	case "@index":
+		v = l.index
+	case "@number":
+		v = l.index + 1
+	case "@first":
+		v = l.index == 0
+	case "@last":
+		v = l.last
+	case "@odd":
+		v = l.index%2 == 1
+	case "@even":
+		v = l.index%2 == 0
+	case "@length":
+		if l.length > -1 {
+			v = l.length
+		}
+	case "@key":
+		if l.entry {
+			v = l.key
+		}
+	case "@value":
+		if l.entry {
+			v = l.value
+		}
+	}
+
+	return
+}
@@ -4,6 +4,8 @@ import (
 	"io"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 
 	"github.com/itsmontoya/buffer"
 	"github.com/missionMeteora/toolkit/errors"
@@ -20,11 +22,24 @@ const (
 	charPeriod      = '.'
 	charCarrot      = '^'
 	charGreaterThan = '>'
+	charAt          = '@'
+	charPipe        = '|'
+	charQuote       = '"'
+	charBackslash   = '\\'
+	charUnderscore  = '_'
+	charHyphen      = '-'
+	charLessThan    = '<'
+	charDollar      = '$'
+
+	// modEntries is a section modifier which iterates the key/value pairs of a map
+	modEntries = "@entries"
 
 	lwrCaseStart = 'a'
 	lwrCaseEnd   = 'z'
 	uprCaseStart = 'A'
 	uprCaseEnd   = 'Z'
+	digitStart   = '0'
+	digitEnd     = '9'
 )
 
 const (
@@ -37,6 +52,7 @@ const (
 	stateValueEnd
 	stateValueClosing
 	stateValueClosed
+	stateValueFilter
 
 	stateUnescapedValueStart
 	stateUnescapedValueOpen
@@ -44,6 +60,7 @@ const (
 	stateUnescapedValueClosingA
 	stateUnescapedValueClosingB
 	stateUnescapedValueClosed
+	stateUnescapedValueFilter
 
 	stateSectionStart
 	stateSectionOpen
@@ -62,6 +79,14 @@ const (
 	stateTmplEnd
 	stateTmplClosing
 
+	stateMessageStart
+	stateMessageOpen
+	stateMessageClosing
+
+	stateClosingStart
+	stateClosingOpen
+	stateClosingEnd
+
 	stateRootEnd
 
 	stateError
@@ -76,8 +101,43 @@ const (
 
 	// ErrUnsupportedType is returned when an upsupported type is provided
 	ErrUnsupportedType = errors.Error("unsupported type provided")
+
+	// ErrMissingKey is returned when a value cannot be resolved and OnMissingError is set
+	ErrMissingKey = errors.Error("missing key")
+
+	// ErrUnclosedSection is returned when a section, parent or block does not have a closing tag
+	ErrUnclosedSection = errors.Error("unclosed section")
+
+	// ErrMismatchedSection is returned when a closing tag does not match the section it closes
+	ErrMismatchedSection = errors.Error("mismatched section closing tag")
 )
 
+// ParseError is returned when a template cannot be parsed
+type ParseError struct {
+	// Name is the name of the partial or layout file the error is within, empty for the template itself
+	Name string
+
+	Offset int // Byte offset, starting at 0
+	Line   int // Line number, starting at 1
+	Column int // Byte offset within the line, starting at 1
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	msg := e.Err.Error() + " at " + strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Column)
+	if len(e.Name) > 0 {
+		return e.Name + ": " + msg
+	}
+
+	return msg
+}
+
+// Unwrap will return the cause of the error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
 var bp = buffer.NewPool(32)
 
 //var bp = newPool()
@@ -88,21 +148,51 @@ func Render(a, b, c interface{}) error {
 }
 
 // Parse will parse a byteslice template and return a mustache Template
-func Parse(tmpl []byte, filePath string) (t *Template, err error) {
+func Parse(tmpl []byte, filePath string, opts ...Option) (t *Template, err error) {
+	if t, err = parseTemplate(tmpl, filePath, newOptions(opts)); err != nil {
+		return
+	}
+
+	t.bp = newBufferPool(len(tmpl))
+	return
+}
+
+func parseTemplate(tmpl []byte, fp string, o *options) (t *Template, err error) {
+	return parseNested(tmpl, fp, o, nesting{})
+}
+
+// parseNested will parse a template nested within another, E.g. a partial loaded from a file
+func parseNested(tmpl []byte, fp string, o *options, n nesting) (t *Template, err error) {
 	var tkns tokens
-	if tkns, err = parse(tmpl, filePath); err != nil {
+	if tkns, err = parse(tmpl, fp, o, n); err != nil {
+		if pe, ok := err.(*ParseError); ok && pe.Line == 0 {
+			pos := newNodeBuilder(tmpl).position(pe.Offset)
+			pe.Line, pe.Column = pos.Line, pos.Column
+		}
+
 		return
 	}
 
-	t = newTemplate(tmpl, tkns)
+	if o.standalone {
+		stripStandalone(tmpl, tkns)
+	}
+
+	t = newTemplate(tmpl, tkns, o)
 	return
 }
 
-func parse(tmpl []byte, fp string) (tkns tokens, err error) {
+func parse(tmpl []byte, fp string, o *options, n nesting) (tkns tokens, err error) {
+	if err = o.limits.checkParse(len(tmpl), n.depth, n.partials); err != nil {
+		return
+	}
+
 	p := parser{
 		kbuf: bp.Get(),
 		tmpl: tmpl,
 		fp:   fp,
+		o:    o,
+		n:    n,
+		epos: -1,
 	}
 
 	if err = p.parse(); err != nil {
@@ -125,7 +215,44 @@ type parser struct {
 
 	tkns tokens
 
-	fp string // Filepath
+	mod  string // Section modifier
+	kind byte   // Opening character of the current section or partial tag
+
+	fstart  int    // Start of the filter pipeline
+	filters []byte // Filter pipeline of the current value
+	quoted  bool   // Within a quoted filter argument
+
+	err  error // Specific cause of stateError, ErrInvalidSyntax is used when nil
+	epos int   // Offset of the error, the current index is used when -1
+
+	fp string   // Filepath
+	o  *options // Options shared with sub-templates
+
+	tstart int     // Start of the current tag
+	n      nesting // Nesting of the template within the template which loaded it
+
+	stack []frame // Open sections, parents and blocks
+	base  int     // Start of the body of the innermost open section, token offsets are relative to it
+}
+
+// nesting is the depth of a template loaded by another
+type nesting struct {
+	depth    int  // Section nesting depth
+	partials int  // Partial nesting depth
+	message  bool // Template of a Catalog message, parsed as it is rendered
+}
+
+// frame is a section, parent or block whose closing tag has not been parsed. The tokens of its body
+// are parsed into the tokens of the parser, and the tokens of the enclosing template are kept by the frame
+type frame struct {
+	kind    byte   // Opening character of the tag, E.g. # for a section
+	key     string // Key of a section, or name of a parent or block
+	entries bool   // Iterate the key/value pairs of a map
+	name    string // Name expected by the closing tag, E.g. items or @entries prices
+
+	tstart int    // Start of the opening tag
+	base   int    // Base offset of the enclosing template
+	tkns   tokens // Tokens of the enclosing template
 }
 
 func (p *parser) parse() (err error) {
@@ -186,15 +313,45 @@ func (p *parser) parse() (err error) {
 		case stateTmplClosing:
 			p.tmplClosing(v)
 
+		case stateMessageStart:
+			p.messageStart(v)
+		case stateMessageOpen:
+			p.messageOpen(v)
+		case stateMessageClosing:
+			p.messageClosing(v)
+
+		case stateClosingStart:
+			p.closingStart(v)
+		case stateClosingOpen:
+			p.closingOpen(v)
+		case stateClosingEnd:
+			p.closingEnd(v)
+
 		case stateRootEnd:
 			break
 
-		case stateError:
-			err = ErrInvalidSyntax
+		case stateValueFilter:
+			p.valueFilter(v, stateValueClosing)
+		case stateUnescapedValueFilter:
+			p.valueFilter(v, stateUnescapedValueClosingA)
+		}
+
+		if p.state == stateError {
 			goto END
 		}
 	}
 
+	if p.state != stateRootStart && p.state != stateContainerStart {
+		// Template ended within a tag
+		p.state = stateError
+		goto END
+	}
+
+	if len(p.stack) > 0 {
+		p.fail(ErrUnclosedSection, p.stack[len(p.stack)-1].tstart)
+		goto END
+	}
+
 	if p.start > -1 {
 		p.tkns = append(p.tkns, tmplToken{
 			start: p.start,
@@ -203,6 +360,21 @@ func (p *parser) parse() (err error) {
 	}
 
 END:
+	if p.state == stateError {
+		if err = p.err; err == nil {
+			err = ErrInvalidSyntax
+		}
+
+		if _, ok := err.(*ParseError); !ok {
+			// Errors of sub-templates already have their position set
+			if p.epos == -1 {
+				p.epos = p.idx
+			}
+
+			err = &ParseError{Offset: p.epos, Err: err}
+		}
+	}
+
 	bp.Put(p.kbuf)
 	p.kbuf = nil
 	p.tmpl = nil
@@ -228,27 +400,34 @@ func (p *parser) containerStart(b byte) {
 	}
 
 	p.tkns = append(p.tkns, tmplToken{
-		start: p.start,
-		end:   p.idx - 1,
+		start: p.start - p.base,
+		end:   p.idx - 1 - p.base,
 	})
 
+	p.tstart = p.idx - 1
 	p.state = stateContainerOpen
 }
 
 func (p *parser) containerOpen(b byte) {
 	switch {
 	case isWhiteSpace(b):
-	case isChar(b), b == charPeriod:
+	case isChar(b), b == charPeriod, b == charAt:
 		p.kstart = p.idx
 		p.state = stateValueOpen
 	case b == charLCurly:
 		p.state = stateUnescapedValueStart
-	case b == charPound:
+	case b == charPound, b == charDollar:
+		p.kind = b
 		p.state = stateSectionStart
 	case b == charCarrot:
 		p.state = stateInvertedSectionStart
-	case b == charGreaterThan:
+	case b == charGreaterThan, b == charLessThan:
+		p.kind = b
 		p.state = stateTmplStart
+	case b == charUnderscore:
+		p.state = stateMessageStart
+	case b == charFSlash:
+		p.state = stateClosingStart
 
 	default:
 		p.state = stateError
@@ -265,6 +444,10 @@ func (p *parser) valueOpen(b byte) {
 	case b == charRCurly:
 		p.kbuf.Write(p.tmpl[p.kstart:p.idx])
 		p.state = stateValueClosing
+	case b == charPipe:
+		p.kbuf.Write(p.tmpl[p.kstart:p.idx])
+		p.fstart = p.idx
+		p.state = stateValueFilter
 	default:
 		p.state = stateError
 	}
@@ -274,24 +457,57 @@ func (p *parser) valueEnd(b byte) {
 	switch {
 	case b == charRCurly:
 		p.state = stateValueClosing
+	case b == charPipe:
+		p.fstart = p.idx
+		p.state = stateValueFilter
 	case isWhiteSpace(b):
 	default:
 		p.state = stateError
 	}
 }
 
+// valueFilter consumes a filter pipeline (E.g. | truncate 40 "...") until the closing curly brace
+func (p *parser) valueFilter(b byte, closing uint8) {
+	switch {
+	case p.quoted && b == charBackslash:
+		p.idx++
+	case b == charQuote:
+		p.quoted = !p.quoted
+	case p.quoted:
+	case b == charRCurly:
+		p.filters = p.tmpl[p.fstart:p.idx]
+		p.state = closing
+	}
+}
+
 func (p *parser) valueClosing(b byte, escape bool) {
 	if b != charRCurly {
 		p.state = stateError
 		return
 	}
 
+	var (
+		fcs []filterCall
+		err error
+	)
+
+	if fcs, err = parseFilters(p.filters, p.o.filters, p.o.anyFilters); err != nil {
+		p.fail(err, p.tstart)
+		return
+	}
+
+	key := p.kbuf.String()
 	p.tkns = append(p.tkns, valToken{
-		key:    p.kbuf.String(),
-		escape: escape,
+		key:     key,
+		id:      p.keyID(key),
+		escape:  escape,
+		filters: fcs,
+		pos:     p.tstart - p.base,
+		end:     p.idx + 1 - p.base,
 	})
 
 	p.kbuf.Reset()
+	p.filters = nil
 	p.start = -1
 	p.kstart = -1
 	p.state = stateRootStart
@@ -300,7 +516,7 @@ func (p *parser) valueClosing(b byte, escape bool) {
 func (p *parser) unescapedValueStart(b byte) {
 	switch {
 	case isWhiteSpace(b):
-	case isChar(b):
+	case isChar(b), b == charAt:
 		p.kstart = p.idx
 		p.state = stateUnescapedValueOpen
 	default:
@@ -318,6 +534,10 @@ func (p *parser) unescapedValueOpen(b byte) {
 	case b == charRCurly:
 		p.kbuf.Write(p.tmpl[p.kstart:p.idx])
 		p.state = stateUnescapedValueClosingA
+	case b == charPipe:
+		p.kbuf.Write(p.tmpl[p.kstart:p.idx])
+		p.fstart = p.idx
+		p.state = stateUnescapedValueFilter
 	default:
 		p.state = stateError
 	}
@@ -327,6 +547,9 @@ func (p *parser) unescapedValueEnd(b byte) {
 	switch {
 	case b == charRCurly:
 		p.state = stateUnescapedValueClosingA
+	case b == charPipe:
+		p.fstart = p.idx
+		p.state = stateUnescapedValueFilter
 	case isWhiteSpace(b):
 	default:
 		p.state = stateError
@@ -343,7 +566,7 @@ func (p *parser) unescapedValueClosing(b byte) {
 
 func (p *parser) sectionStart(b byte) {
 	switch {
-	case isChar(b), b == charPeriod:
+	case isChar(b), b == charPeriod, b == charAt:
 		p.state = stateSectionOpen
 		p.kstart = p.idx
 	case isWhiteSpace(b):
@@ -372,6 +595,12 @@ func (p *parser) sectionEnd(b byte) {
 	case b == charRCurly:
 		p.state = stateSectionClosing
 	case isWhiteSpace(b):
+	case (isChar(b) || b == charPeriod) && p.kind == charPound && len(p.mod) == 0 && p.kbuf.String() == modEntries:
+		// Section modifiers are followed by the key they apply to
+		p.mod = modEntries
+		p.kbuf.Reset()
+		p.kstart = p.idx
+		p.state = stateSectionOpen
 	default:
 		p.state = stateError
 	}
@@ -383,38 +612,20 @@ func (p *parser) sectionClosing(b byte) {
 		return
 	}
 
-	p.idx++
-	var ss, se int
-	if ss, se = findSectionEnd(p.tmpl[p.idx:]); se == -1 {
-		p.state = stateError
-		return
+	f := frame{kind: p.kind, key: p.kbuf.String()}
+	f.name = f.key
+	if len(p.mod) > 0 {
+		f.entries = p.mod == modEntries
+		f.name = p.mod + " " + f.key
 	}
 
-	var (
-		st  *Template
-		err error
-	)
-
-	if st, err = Parse(p.tmpl[p.idx:p.idx+ss], p.fp); err != nil {
-		p.state = stateError
-		return
-	}
-
-	p.tkns = append(p.tkns, sectionToken{
-		key: p.kbuf.String(),
-		t:   st,
-	})
-	p.idx += se
-
-	p.kbuf.Reset()
-	p.start = -1
-	p.kstart = -1
-	p.state = stateRootStart
+	p.mod = ""
+	p.open(f)
 }
 
 func (p *parser) invertedSectionStart(b byte) {
 	switch {
-	case isChar(b), b == charPeriod:
+	case isChar(b), b == charPeriod, b == charAt:
 		p.state = stateInvertedSectionOpen
 		p.kstart = p.idx
 	case isWhiteSpace(b):
@@ -454,38 +665,13 @@ func (p *parser) invertedSectionClosing(b byte) {
 		return
 	}
 
-	p.idx++
-	var ss, se int
-	if ss, se = findSectionEnd(p.tmpl[p.idx:]); se == -1 {
-		p.state = stateError
-		return
-	}
-
-	var (
-		st  *Template
-		err error
-	)
-
-	if st, err = Parse(p.tmpl[p.idx:p.idx+ss], p.fp); err != nil {
-		p.state = stateError
-		return
-	}
-
-	p.tkns = append(p.tkns, invertedSectionToken{
-		key: p.kbuf.String(),
-		t:   st,
-	})
-	p.idx += se
-
-	p.kbuf.Reset()
-	p.start = -1
-	p.kstart = -1
-	p.state = stateRootStart
+	key := p.kbuf.String()
+	p.open(frame{kind: charCarrot, key: key, name: key})
 }
 
 func (p *parser) tmplStart(b byte) {
 	switch {
-	case isChar(b), b == charPeriod:
+	case isNameChar(b):
 		p.state = stateTmplOpen
 		p.kstart = p.idx
 	case isWhiteSpace(b):
@@ -496,7 +682,7 @@ func (p *parser) tmplStart(b byte) {
 
 func (p *parser) tmplOpen(b byte) {
 	switch {
-	case isChar(b), b == charPeriod, b == charFSlash:
+	case isNameChar(b):
 	case isWhiteSpace(b):
 		p.kbuf.Write(p.tmpl[p.kstart:p.idx])
 		p.state = stateTmplEnd
@@ -524,101 +710,250 @@ func (p *parser) tmplClosing(b byte) {
 		return
 	}
 
+	if p.kind == charLessThan {
+		name := p.kbuf.String()
+		p.open(frame{kind: charLessThan, key: name, name: name})
+		return
+	}
+
+	p.partialClosing()
+	p.kbuf.Reset()
+	p.start = -1
+	p.kstart = -1
+	if p.state != stateError {
+		p.state = stateRootStart
+	}
+}
+
+func (p *parser) partialClosing() {
+	name := p.kbuf.String()
+	if p.o.set != nil {
+		// Partials of a Set are resolved by name when rendered
+		p.tkns = append(p.tkns, partialToken{name: name, pos: p.tstart - p.base, end: p.idx + 1 - p.base})
+		return
+	}
+
 	var (
-		f   *os.File
 		st  sectionToken
 		err error
-
-		buf = bp.Get() // We are not going to return this to the pool until we copy the bytes properly
 	)
 
-	if f, err = os.Open(path.Join(p.fp, p.kbuf.String())); err != nil {
+	st.key = "."
+	st.id = noKey
+	st.partial = name
+	st.pos = p.tstart - p.base
+	st.end = p.idx + 1 - p.base
+	if st.t, err = p.loadTemplate(name); err != nil {
+		p.fail(partialError(name, err), p.tstart)
+		return
+	}
+
+	p.tkns = append(p.tkns, st)
+}
+
+// open will start the body of a section, parent or block. The opening tag ends at the current index
+func (p *parser) open(f frame) {
+	f.tstart = p.tstart
+	f.base = p.base
+	f.tkns = p.tkns
+	p.stack = append(p.stack, f)
+
+	if exceeds(p.o.limits.MaxDepth, p.depth()) {
+		p.fail(ErrDepthExceeded, p.tstart)
+		return
+	}
+
+	p.tkns = nil
+	p.base = p.idx + 1
+
+	p.kbuf.Reset()
+	p.start = -1
+	p.kstart = -1
+	p.state = stateRootStart
+}
+
+func (p *parser) closingStart(b byte) {
+	switch {
+	case isNameChar(b), b == charAt:
+		p.kstart = p.idx
+		p.state = stateClosingOpen
+	case isWhiteSpace(b):
+	default:
 		p.state = stateError
-		goto END
 	}
+}
 
-	io.Copy(buf, f)
+func (p *parser) closingOpen(b byte) {
+	switch {
+	case isNameChar(b), b == charAt, isWhiteSpace(b):
+	case b == charRCurly:
+		p.kbuf.Write(p.tmpl[p.kstart:p.idx])
+		p.state = stateClosingEnd
+	default:
+		p.state = stateError
+	}
+}
 
-	st.key = "."
-	if st.t, err = Parse(buf.Bytes(), p.fp); err != nil {
+// closingEnd will end the body of the innermost open section, parent or block
+func (p *parser) closingEnd(b byte) {
+	if b != charRCurly {
 		p.state = stateError
-		goto END
+		return
 	}
 
-	p.tkns = append(p.tkns, st)
+	// Closing tags are normalized, E.g. {{/   @entries  prices }} closes {{# @entries prices }}
+	name := strings.Join(strings.Fields(p.kbuf.String()), " ")
+	if len(p.stack) == 0 || p.stack[len(p.stack)-1].name != name {
+		p.fail(ErrMismatchedSection, p.tstart)
+		return
+	}
 
-END:
-	if f != nil {
-		f.Close()
+	f := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	var (
+		t   = newTemplate(p.tmpl[p.base:p.tstart], p.tkns, p.o)
+		pos = f.tstart - f.base
+		end = p.idx + 1 - f.base
+		tkn token
+	)
+
+	t.offset = p.base
+	switch f.kind {
+	case charPound:
+		tkn = sectionToken{key: f.key, id: p.keyID(f.key), t: t, entries: f.entries, pos: pos, end: end}
+	case charCarrot:
+		tkn = invertedSectionToken{key: f.key, id: p.keyID(f.key), t: t, pos: pos, end: end}
+	case charDollar:
+		tkn = blockToken{name: f.key, t: t, pos: pos, end: end}
+	case charLessThan:
+		pt := parentToken{name: f.key, t: t, pos: pos, end: end}
+		if p.o.set == nil {
+			// Layouts of a Set are resolved by name when rendered
+			var err error
+			if pt.layout, err = p.loadTemplate(pt.name); err != nil {
+				p.fail(partialError(pt.name, err), f.tstart)
+				return
+			}
+		}
+
+		tkn = pt
 	}
 
+	p.tkns = append(f.tkns, tkn)
+	p.base = f.base
+
 	p.kbuf.Reset()
 	p.start = -1
 	p.kstart = -1
 	p.state = stateRootStart
 }
 
-func findSectionEnd(in []byte) (start, i int) {
+// keyID will return the ID of a key. The keys of Catalog messages are not interned, as messages are
+// parsed as they are rendered and the IDs of an IndexedAficionado are fixed by Keys. Keys which are
+// only used by messages have no ID
+func (p *parser) keyID(key string) (id int) {
+	if !p.n.message {
+		return p.o.keys.intern(key)
+	}
+
+	var ok bool
+	if id, ok = p.o.keys.id(key); !ok {
+		id = noKey
+	}
+
+	return
+}
+
+// depth will return the section nesting depth of the current index
+func (p *parser) depth() int {
+	return p.n.depth + len(p.stack)
+}
+
+// fail will stop parsing with an error at an offset of the template
+func (p *parser) fail(err error, offset int) {
+	p.err = err
+	p.epos = offset
+	p.state = stateError
+}
+
+// partialError will set the name of the partial to the parse error of a partial
+func partialError(name string, err error) error {
+	if pe, ok := err.(*ParseError); ok && len(pe.Name) == 0 {
+		pe.Name = name
+	}
+
+	return err
+}
+
+// loadTemplate will parse a template file relative to the parser's filepath
+func (p *parser) loadTemplate(name string) (t *Template, err error) {
 	var (
-		b     byte
-		state uint8
-		level uint8 = 1
+		f   *os.File
+		buf = bp.Get() // We are not going to return this to the pool until we copy the bytes properly
 	)
 
-	for i, b = range in {
-		switch state {
-		case 0:
-			if b == charLCurly {
-				start = i
-				state = 1
-			}
-		case 1:
-			if b == charLCurly {
-				state = 2
-			} else {
-				state = 0
-			}
-		case 2:
-			if b == charPound || b == charCarrot {
-				level++
-				state = 0
-			} else if b == charFSlash {
-				state = 3
-			} else if !isWhiteSpace(b) {
-				state = 0
-			}
+	if f, err = os.Open(path.Join(p.fp, name)); err != nil {
+		return
+	}
+	defer f.Close()
 
-		case 3:
-			if isChar(b) || b == charPeriod {
-				state = 4
-			} else if !isWhiteSpace(b) {
-				state = 0
-			}
+	if _, err = io.Copy(buf, f); err != nil {
+		return
+	}
 
-		case 4:
-			if isWhiteSpace(b) {
-				state = 5
-			} else if b == charRCurly {
-				state = 6
-			} else if !isChar(b) {
-				state = 0
-			}
-		case 5:
-			if b == charRCurly {
-				state = 6
-			} else if !isWhiteSpace(b) {
-				state = 0
-			}
-		case 6:
-			level--
-			state = 0
+	// Partials are the root template of their own source
+	return parseNested(buf.Bytes(), p.fp, p.o, nesting{depth: p.depth() + 1, partials: p.n.partials + 1, message: p.n.message})
+}
 
-			if level == 0 {
-				//	i++
-				return
-			}
-		}
+func (p *parser) messageStart(b byte) {
+	switch {
+	case isWhiteSpace(b):
+	case isChar(b):
+		p.kstart = p.idx
+		p.state = stateMessageOpen
+	default:
+		p.state = stateError
 	}
+}
 
-	return -1, -1
+func (p *parser) messageOpen(b byte) {
+	switch {
+	case isChar(b), isWhiteSpace(b), b == charPeriod, b == charUnderscore, b == charAt:
+	case b == charRCurly:
+		p.kbuf.Write(p.tmpl[p.kstart:p.idx])
+		p.state = stateMessageClosing
+	default:
+		p.state = stateError
+	}
+}
+
+func (p *parser) messageClosing(b byte) {
+	if b != charRCurly {
+		p.state = stateError
+		return
+	}
+
+	// Message tags are formatted as {{_ id }} or {{_ id countKey }}
+	fields := strings.Fields(p.kbuf.String())
+	switch len(fields) {
+	case 1:
+		p.tkns = append(p.tkns, messageToken{id: fields[0], countID: noKey, pos: p.tstart - p.base, end: p.idx + 1 - p.base})
+	case 2:
+		p.tkns = append(p.tkns, messageToken{
+			id:      fields[0],
+			count:   fields[1],
+			countID: p.keyID(fields[1]),
+			pos:     p.tstart - p.base,
+			end:     p.idx + 1 - p.base,
+		})
+	default:
+		p.state = stateError
+		return
+	}
+
+	p.kbuf.Reset()
+	p.start = -1
+	p.kstart = -1
+	p.state = stateRootStart
 }
@@ -1,13 +1,23 @@
 package mustache
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	hmust "github.com/hoisie/mustache"
-	"github.com/itsmontoya/mustache/profiling"
 )
 
 var (
@@ -16,6 +26,7 @@ var (
 	exampleInjectionStr         = "<div>{{ injection }}</div>"
 	exampleApprovedInjectionStr = "<head>{{{approvedInjection}}}</head>"
 	exampleArrayHTMLStr         = "<ul>{{# . }}<li>{{ greeting }} {{ name }}!</li>{{/ . }}</ul>{{^ . }}<div><p>Oh noes!</div></p>{{/ . }}"
+	exampleStringMapStr         = "<p>Hello {{ name }} ({{ count }})</p><ul>{{# items }}<li>{{ @number }}. {{ name }}</li>{{/ items }}</ul>"
 	exampleLongStr              = `
 	But I must explain to you {{ name }} how all this mistaken idea of denouncing pleasure and praising pain was born and I will give you a complete account of the system, and expound the actual teachings of the great explorer of the truth, the master-builder of human happiness. May I ask you, {{ question }}? No one rejects, dislikes, or avoids pleasure itself, because it is pleasure, but because those who do not know how to pursue pleasure rationally encounter consequences that are extremely painful.
 
@@ -52,15 +63,20 @@ var (
 		},
 	}
 
+	users = []map[string]string{
+		{"greeting": "Hello", "name": "Panda"},
+		{"greeting": "Howdy", "name": "Koala"},
+	}
+
 	errInvalidOutput = errors.New("invalid output")
 
 	outputB   []byte
 	outputStr string
 )
 
-func test(tmpl []byte, d interface{}) (err error) {
+func test(tmpl []byte, d interface{}, opts ...Option) (err error) {
 	var t *Template
-	if t, err = Parse(tmpl); err != nil {
+	if t, err = Parse(tmpl, "", opts...); err != nil {
 		fmt.Println("Parse error", err)
 		return
 	}
@@ -76,6 +92,45 @@ func test(tmpl []byte, d interface{}) (err error) {
 	return
 }
 
+// testCase is a template rendered with data, and the output or error it is expected to render
+type testCase struct {
+	tmpl string
+	opts []Option
+	loc  *Locale
+	data interface{}
+
+	expected string
+	err      error
+}
+
+// run will parse and render the template of a test case
+func (tc testCase) run() (out string, err error) {
+	var t *Template
+	if t, err = Parse([]byte(tc.tmpl), "", tc.opts...); err != nil {
+		return
+	}
+
+	err = t.RenderLocale(tc.data, tc.loc, func(b []byte) {
+		out = string(b)
+	})
+
+	return
+}
+
+// testCases will run each test case, reporting unexpected output and errors
+func testCases(t *testing.T, tcs []testCase) {
+	t.Helper()
+	for _, tc := range tcs {
+		out, err := tc.run()
+		switch {
+		case !errors.Is(err, tc.err):
+			t.Errorf("invalid error for %q, expected %v and received %v", tc.tmpl, tc.err, err)
+		case err == nil && out != tc.expected:
+			t.Errorf("invalid output for %q, expected %q and received %q", tc.tmpl, tc.expected, out)
+		}
+	}
+}
+
 func TestVerySimple(t *testing.T) {
 	if err := test(exampleVerySimple, m); err != nil {
 		t.Error(err)
@@ -106,6 +161,1211 @@ func TestMissingArrayHTML(t *testing.T) {
 	//}
 }
 
+func TestMissing(t *testing.T) {
+	tmpl := []byte("<p>{{ name }} {{ nickname }} {{{ nickname }}} {{nickname|truncate 4}}</p>")
+	tests := []struct {
+		opt      Option
+		expected string
+	}{
+		{OnMissingLiteral("N/A"), "<p>Panda N/A N/A N/A</p>"},
+		{OnMissingRaw(), "<p>Panda {{ nickname }} {{{ nickname }}} {{nickname|truncate 4}}</p>"},
+		{OnMissingFunc(func(key string) interface{} { return "<" + key + ">" }), "<p>Panda &lt;nickname&gt; <nickname> &lt;nickname&gt;</p>"},
+	}
+
+	for _, tc := range tests {
+		var (
+			tp  *Template
+			err error
+		)
+
+		if tp, err = Parse(tmpl, "", tc.opt); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = tp.Render(m, func(b []byte) {
+			if string(b) != tc.expected {
+				t.Errorf("invalid output, expected %q and received %q", tc.expected, string(b))
+			}
+		}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	tp, err := Parse(tmpl, "", OnMissingError())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = tp.Render(m, func([]byte) {}); err != ErrMissingKey {
+		t.Errorf("invalid error, expected %v and received %v", ErrMissingKey, err)
+	}
+}
+
+func TestSectionValues(t *testing.T) {
+	tmpl := []byte("[{{# list }}{{ . }},{{/ list }}{{^ list }}empty{{/ list }}]")
+	tests := []struct {
+		val      interface{}
+		expected string
+	}{
+		{[]uint16{1, 2}, "[1,2,]"},
+		{[]int8{3}, "[3,]"},
+		{[]bool{true, false}, "[true,false,]"},
+		{[2]float32{1.5, 2}, "[1.5,2,]"},
+		{[]interface{}{"a", 1}, "[a,1,]"},
+		{[]byte("ab"), "[ab,]"},
+		{json.RawMessage("12"), "[12,]"},
+		{[]byte{}, "[empty]"},
+		{[]uint64{}, "[empty]"},
+		{map[string]int{}, "[empty]"},
+		{"", "[empty]"},
+		{false, "[empty]"},
+		{nil, "[empty]"},
+	}
+
+	for _, tc := range tests {
+		var (
+			tp  *Template
+			err error
+		)
+
+		if tp, err = Parse(tmpl, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = tp.Render(map[string]interface{}{"list": tc.val}, func(b []byte) {
+			if string(b) != tc.expected {
+				t.Errorf("invalid output for %T, expected %q and received %q", tc.val, tc.expected, string(b))
+			}
+		}); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestValueFallbacks(t *testing.T) {
+	var (
+		tp  *Template
+		err error
+
+		name = "Panda"
+	)
+
+	if tp, err = Parse([]byte("{{ date }} {{ err }} {{ name }} {{ nilName }}"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"date":    time.Date(2017, 1, 18, 0, 0, 0, 0, time.UTC),
+		"err":     errors.New("oh gosh"),
+		"name":    &name,
+		"nilName": (*string)(nil),
+	}
+
+	expected := "2017-01-18T00:00:00Z oh gosh Panda "
+	if err = tp.Render(data, func(b []byte) {
+		if string(b) != expected {
+			t.Errorf("invalid output, expected %q and received %q", expected, string(b))
+		}
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSectionStreams(t *testing.T) {
+	var (
+		tp  *Template
+		err error
+	)
+
+	if tp, err = Parse([]byte("[{{# rows }}{{ . }},{{/ rows }}]"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan string, 2)
+	ch <- "a"
+	ch <- "b"
+	close(ch)
+
+	var seq func(yield func(int) bool) = func(yield func(int) bool) {
+		for i := 1; i <= 3 && yield(i); i++ {
+		}
+	}
+
+	tests := []struct {
+		rows     interface{}
+		expected string
+	}{
+		{(<-chan string)(ch), "[a,b,]"},
+		{seq, "[1,2,3,]"},
+		{&testIterator{n: 2}, "[1,2,]"},
+	}
+
+	for _, tc := range tests {
+		if err = tp.Render(map[string]interface{}{"rows": tc.rows}, func(b []byte) {
+			if string(b) != tc.expected {
+				t.Errorf("invalid output for %T, expected %q and received %q", tc.rows, tc.expected, string(b))
+			}
+		}); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+type testIterator struct {
+	i int
+	n int
+}
+
+func (it *testIterator) Next() (a Aficionado, ok bool) {
+	if it.i == it.n {
+		return
+	}
+
+	it.i++
+	return Value{it.i}, true
+}
+
+func TestLoopMetadata(t *testing.T) {
+	testCases(t, []testCase{
+		{
+			tmpl: "{{# list }}{{ @number }}. {{ name }}{{^ @last }}, {{/ @last }}{{/ list }}",
+			data: map[string]interface{}{
+				"list": []map[string]string{
+					{"name": "Panda", "@number": "clobbered"},
+					{"name": "Koala"},
+				},
+			},
+			expected: "1. Panda, 2. Koala",
+		},
+	})
+}
+
+func TestEntries(t *testing.T) {
+	testCases(t, []testCase{
+		{
+			tmpl: "{{# @entries headers }}{{ @key }}: {{ @value }}\n{{/ @entries headers }}",
+			data: map[string]interface{}{
+				"headers": map[string]string{
+					"X-Request-Id": "42",
+					"Content-Type": "text/plain",
+				},
+			},
+			expected: "Content-Type: text/plain\nX-Request-Id: 42\n",
+		},
+	})
+}
+
+func TestFilters(t *testing.T) {
+	shout := func(v interface{}, args []string, loc *Locale) (interface{}, error) {
+		return fmt.Sprintf("%s!", v), nil
+	}
+
+	data := map[string]interface{}{
+		"name":  "Panda",
+		"price": 12.5,
+		"bio":   "Really appreciates the Mustache",
+	}
+
+	defaults := map[string]interface{}{
+		"active": true,
+		"admin":  false,
+		"tags":   []string{},
+		"count":  0,
+	}
+
+	testCases(t, []testCase{
+		{
+			tmpl:     `{{ name | upper }} {{ price | currency "EUR" }} {{ bio | truncate 8 }} {{ nickname | default "friend" }} {{ name | shout }}`,
+			opts:     []Option{WithFilters(Filters{"shout": shout})},
+			data:     data,
+			expected: "PANDA €12.50 Really a... friend Panda!",
+		},
+		{
+			tmpl:     `{{ active | default "no" }} {{ admin | default "no" }} {{ tags | default "none" }} {{ count | default "none" }}`,
+			data:     defaults,
+			expected: "true false none 0",
+		},
+		{tmpl: "{{ name | unknown }}", data: data, err: ErrUnknownFilter},
+		{tmpl: "{{ bio | truncate -1 }}", data: data, err: ErrInvalidFilterArgs},
+	})
+}
+
+func TestLocale(t *testing.T) {
+	tmpl := `{{ price }} {{ price | number }} {{ price | currency "EUR" }} {{ date | date "date" }}`
+	data := map[string]interface{}{
+		"price": 1234.5,
+		"date":  time.Date(2017, 1, 18, 0, 0, 0, 0, time.UTC),
+	}
+
+	testCases(t, []testCase{
+		{tmpl: tmpl, data: data, expected: "1234.5 1234.5 €1234.50 2017-01-18"},
+		{tmpl: tmpl, data: data, loc: LocaleEnUS, expected: "1,234.5 1,234.5 €1,234.50 01/18/2017"},
+		{tmpl: tmpl, data: data, loc: LocaleDeDE, expected: "1.234,5 1.234,5 1.234,50 € 18.01.2017"},
+	})
+}
+
+func TestMessages(t *testing.T) {
+	c := WithCatalog(MessageCatalog{
+		"":   {"welcome": "Welcome, {{ name }}!", "cart": "one item|{{ count }} items"},
+		"de": {"welcome": "Willkommen, {{ name }}!", "cart": "ein Artikel|{{ count }} Artikel"},
+	})
+
+	tmpl := "{{_ welcome }} {{_ cart count }}"
+	count := func(n int) map[string]interface{} {
+		return map[string]interface{}{"name": "Panda", "count": n}
+	}
+
+	testCases(t, []testCase{
+		{tmpl: tmpl, opts: []Option{c}, data: count(1), expected: "Welcome, Panda! one item"},
+		{tmpl: tmpl, opts: []Option{c}, data: count(3), loc: LocaleEnUS, expected: "Welcome, Panda! 3 items"},
+		{tmpl: tmpl, opts: []Option{c}, data: count(3), loc: LocaleDeDE, expected: "Willkommen, Panda! 3 Artikel"},
+
+		// Missing counts are 0 unless the missing value option provides them
+		{
+			tmpl:     "{{_ cart count }}",
+			opts:     []Option{c, OnMissingLiteral("?")},
+			data:     map[string]interface{}{},
+			expected: "? items",
+		},
+		{
+			tmpl:     "{{_ cart count }}",
+			opts:     []Option{c, OnMissingFunc(func(key string) interface{} { return 1 })},
+			data:     map[string]interface{}{},
+			expected: "one item",
+		},
+		{
+			tmpl: "{{_ cart count }}",
+			opts: []Option{c, OnMissingError()},
+			data: map[string]interface{}{},
+			err:  ErrMissingKey,
+		},
+	})
+}
+
+func TestSet(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.mustache":  {Data: []byte("<title>{{$ title }}Untitled{{/ title }}</title>{{$ body }}{{/ body }}")},
+		"partials/user.mustache": {Data: []byte("<p>{{ name }}</p>")},
+		"users.mustache":         {Data: []byte("{{< layouts/base }}{{$ body }}{{# users }}{{> partials/user }}{{/ users }}{{/ body }}{{/ layouts/base }}")},
+		"README.md":              {Data: []byte("Not a template {{")},
+	}
+
+	var (
+		s   *Set
+		buf bytes.Buffer
+		err error
+	)
+
+	if s, err = ParseFS(fsys, []string{".mustache"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"users": []map[string]string{{"name": "Panda"}, {"name": "Koala"}},
+	}
+
+	if err = s.Execute(&buf, "users", data); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<title>Untitled</title><p>Panda</p><p>Koala</p>"
+	if buf.String() != expected {
+		t.Errorf("invalid output, expected %q and received %q", expected, buf.String())
+	}
+
+	if err = s.Execute(&buf, "README", nil); err != ErrTemplateNotFound {
+		t.Errorf("invalid error, expected %v and received %v", ErrTemplateNotFound, err)
+	}
+}
+
+func TestSetReload(t *testing.T) {
+	var (
+		s   *Set
+		buf bytes.Buffer
+		err error
+
+		dir = t.TempDir()
+	)
+
+	write := func(name, tmpl string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(tmpl), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("page.mustache", "<div>{{> name }}</div>")
+	write("name.mustache", "{{ name }}")
+	if s, err = ParseDir(dir, []string{".mustache"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The page is updated without being changed, as it references the partial by name
+	write("name.mustache", "Hello {{ name }}")
+	if err = s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Execute(&buf, "page", m); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "<div>Hello Panda</div>"; buf.String() != expected {
+		t.Errorf("invalid output, expected %q and received %q", expected, buf.String())
+	}
+
+	write("name.mustache", "Hello {{ name ")
+	if err = s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Execute(&buf, "page", m); !errors.Is(err, ErrInvalidSyntax) {
+		t.Errorf("invalid error, expected %v and received %v", ErrInvalidSyntax, err)
+	}
+}
+
+func TestConcurrentRender(t *testing.T) {
+	var (
+		tp  *Template
+		err error
+	)
+
+	tmpl := []byte("{{ title | upper }}{{# users }}<{{ @number }}:{{ name }}>{{/ users }}{{^ empty }}!{{/ empty }}")
+	if tp, err = Parse(tmpl, "", OnMissingError()); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := map[string]interface{}{
+		"title": "users",
+		"users": []map[string]string{{"name": "Panda"}, {"name": "Koala"}},
+	}
+
+	invalid := map[string]interface{}{
+		"title": "users",
+		"users": []interface{}{InterfaceMap{"name": "Panda"}, errAficionado{}},
+	}
+
+	missing := map[string]interface{}{
+		"users": []map[string]string{{"name": "Panda"}},
+	}
+
+	expected := "USERS<1:Panda><2:Koala>!"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				switch (i + j) % 3 {
+				case 0:
+					if err := tp.Render(valid, func(b []byte) {
+						if string(b) != expected {
+							t.Errorf("invalid output, expected %q and received %q", expected, string(b))
+						}
+					}); err != nil {
+						t.Error(err)
+					}
+				case 1:
+					if err := tp.Render(invalid, func([]byte) {}); err != errInvalidOutput {
+						t.Errorf("invalid error, expected %v and received %v", errInvalidOutput, err)
+					}
+				case 2:
+					if err := tp.Render(missing, func([]byte) {}); err != ErrMissingKey {
+						t.Errorf("invalid error, expected %v and received %v", ErrMissingKey, err)
+					}
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+type errAficionado struct{}
+
+func (errAficionado) MarshalMustache(r *Renderer) error {
+	return errInvalidOutput
+}
+
+func TestExecuteContext(t *testing.T) {
+	var (
+		tp  *Template
+		err error
+	)
+
+	if tp, err = Parse([]byte("{{# rows }}{{ . }},{{/ rows }}"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// rows never ends, so the render is only stopped by the context
+	rows := func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var buf bytes.Buffer
+	if err = tp.ExecuteContext(ctx, &buf, map[string]interface{}{"rows": rows}); err != context.DeadlineExceeded {
+		t.Errorf("invalid error, expected %v and received %v", context.DeadlineExceeded, err)
+	}
+
+	if buf.Len() > 0 {
+		t.Errorf("invalid output, expected no output and received %d bytes", buf.Len())
+	}
+
+	// Channels which never send are stopped by the context, both as Aficionado and reflected channels
+	for _, ch := range []interface{}{make(chan Aficionado), make(chan map[string]string)} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		start := time.Now()
+		if err = tp.ExecuteContext(ctx, &buf, map[string]interface{}{"rows": ch}); err != context.DeadlineExceeded {
+			t.Errorf("invalid error for %T, expected %v and received %v", ch, context.DeadlineExceeded, err)
+		}
+
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("invalid duration for %T, expected the render to stop at the deadline and it took %v", ch, d)
+		}
+
+		cancel()
+	}
+}
+
+func TestLimits(t *testing.T) {
+	data := map[string]interface{}{
+		"name": "Panda",
+		"rows": []int{1, 2, 3, 4},
+		"a":    map[string]interface{}{"b": map[string]interface{}{"c": true}},
+	}
+
+	limits := func(l Limits) []Option {
+		return []Option{WithLimits(l)}
+	}
+
+	testCases(t, []testCase{
+		{tmpl: "Hello {{ name }}", opts: limits(Limits{MaxSourceBytes: 8}), data: data, err: ErrSourceTooLarge},
+		{tmpl: "{{# rows }}{{ . }}-----{{/ rows }}", opts: limits(Limits{MaxOutputBytes: 16}), data: data, err: ErrOutputTooLarge},
+		{tmpl: "{{# rows }}{{ . }}{{/ rows }}", opts: limits(Limits{MaxIterations: 3}), data: data, err: ErrIterationsExceeded},
+		{tmpl: "{{# a }}{{# b }}{{# c }}!{{/ c }}{{/ b }}{{/ a }}", opts: limits(Limits{MaxDepth: 2}), data: data, err: ErrDepthExceeded},
+		{tmpl: "{{# a }}{{# b }}{{# c }}!{{/ c }}{{/ b }}{{/ a }}", opts: limits(Limits{MaxDepth: 3}), data: data, expected: "!"},
+		{tmpl: "{{# rows }}{{ . }}{{/ rows }}", opts: limits(Limits{MaxOutputBytes: 4, MaxIterations: 4}), data: data, expected: "1234"},
+	})
+
+	// Partials which include themselves are stopped by MaxPartialDepth
+	s := NewSet(WithLimits(Limits{MaxPartialDepth: 8}))
+	if err := s.Add("loop", []byte("{{> loop }}")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Execute(&bytes.Buffer{}, "loop", data); err != ErrPartialDepthExceeded {
+		t.Errorf("invalid error, expected %v and received %v", ErrPartialDepthExceeded, err)
+	}
+
+	// Without Limits, the default MaxPartialDepth stops them before the stack overflows
+	s = NewSet()
+	if err := s.Add("loop", []byte("{{> loop }}")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Execute(&bytes.Buffer{}, "loop", data); err != ErrPartialDepthExceeded {
+		t.Errorf("invalid error, expected %v and received %v", ErrPartialDepthExceeded, err)
+	}
+}
+
+type compileItem struct {
+	SKU   string `mustache:"sku"`
+	Price float64
+}
+
+type compilePage struct {
+	Title  string
+	Items  []compileItem
+	Author *struct{ Name string }
+	Tags   map[string]int
+	Flag   interface{}
+}
+
+// compileMain is the source of a program which renders data of a type (E.g. []compileItem) decoded
+// from stdin with the compiled RenderData func
+const compileMain = `package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+type compileItem struct {
+	SKU   string ` + "`mustache:\"sku\"`" + `
+	Price float64
+}
+
+type compilePage struct {
+	Title  string
+	Items  []compileItem
+	Author *struct{ Name string }
+	Tags   map[string]int
+	Flag   interface{}
+}
+
+func main() {
+	var data %s
+	if err := json.NewDecoder(os.Stdin).Decode(&data); err != nil {
+		panic(err)
+	}
+
+	if err := RenderData(os.Stdout, data); err != nil {
+		panic(err)
+	}
+}
+`
+
+func TestCompile(t *testing.T) {
+	var (
+		tp  *Template
+		src []byte
+		err error
+	)
+
+	tmpl := `<h1>{{ title }}</h1>{{# items }}{{ @number }}. {{ sku }} {{ price }}{{^ @last }}, {{/ @last }}{{/ items }}` +
+		`{{# author }} by {{ name }}{{/ author }}{{^ author }} anonymous{{/ author }}` +
+		`{{# @entries tags }}{{ @key }}={{ . }}{{/ @entries tags }}`
+
+	if tp, err = Parse([]byte(tmpl), "", OnMissingError()); err != nil {
+		t.Fatal(err)
+	}
+
+	co := CompileOptions{Package: "pages", PkgPath: "github.com/itsmontoya/mustache", Func: "RenderPage"}
+	if src, err = Compile(tp, reflect.TypeOf(compilePage{}), co); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exp := range []string{
+		"func RenderPage(w io.Writer, data compilePage) (err error) {",
+		"mustache.WriteString(w, data.Title, true)",
+		"data.Items",
+		"mustache.SortedKeys(",
+	} {
+		if !bytes.Contains(src, []byte(exp)) {
+			t.Errorf("invalid source, expected to contain %q:\n%s", exp, src)
+		}
+	}
+
+	for v, expected := range map[interface{}]bool{true: false, false: true, nil: true, "": true, "a": false, 0: false} {
+		if IsFalsy(v) != expected {
+			t.Errorf("invalid falsiness of %#v, expected %v", v, expected)
+		}
+	}
+
+	// Keys are resolved when compiled
+	if tp, err = Parse([]byte("{{ subtitle }}"), "", OnMissingError()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = Compile(tp, reflect.TypeOf(compilePage{}), co); !errors.Is(err, ErrMissingKey) {
+		t.Errorf("invalid error, expected %v and received %v", ErrMissingKey, err)
+	}
+}
+
+func TestCompileRender(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiled templates are built with the go command")
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	page := `<h1>{{ title }}</h1>{{# items }}{{ @number }}. {{ sku }} {{ price }}{{^ @last }}, {{/ @last }}{{/ items }}` +
+		`{{# author }} by {{ name }}{{/ author }}{{^ author }} anonymous{{/ author }}` +
+		`{{# @entries tags }} {{ @key }}={{ . }}{{/ @entries tags }}{{^ flag }} unflagged{{/ flag }}`
+
+	// Data is decoded by case-insensitive field name, so the same JSON is rendered as map data
+	tests := []struct {
+		tmpl string
+		opts []Option
+		typ  reflect.Type
+		data []string
+	}{
+		{
+			tmpl: page,
+			opts: []Option{OnMissingError()},
+			typ:  reflect.TypeOf(compilePage{}),
+			data: []string{
+				`{"title": "<Cart>", "items": [{"sku": "a1", "price": 12.5}, {"sku": "b2", "price": 3}],` +
+					` "author": {"name": "Panda"}, "tags": {"b": 2, "a": 1}, "flag": true}`,
+				`{"title": "Empty", "flag": false}`,
+			},
+		},
+		{
+			tmpl: `<ul>{{# . }}<li>{{ @number }}. {{ sku }}</li>{{/ . }}</ul>{{^ . }}empty{{/ . }}`,
+			typ:  reflect.TypeOf([]compileItem{}),
+			data: []string{`[{"sku": "a1"}, {"sku": "b2"}]`, `[]`},
+		},
+		{
+			tmpl: `{{ title }}: {{subtitle}}, {{{  subtitle}}} and {{flag }}`,
+			opts: []Option{OnMissingRaw()},
+			typ:  reflect.TypeOf(compilePage{}),
+			data: []string{`{"title": "Cart"}`, `{"title": "Cart", "flag": "set"}`},
+		},
+	}
+
+	for _, tc := range tests {
+		tp, err := Parse([]byte(tc.tmpl), "", tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		run := buildCompiled(t, tp, tc.typ)
+		for _, data := range tc.data {
+			var v interface{}
+			if err = json.Unmarshal([]byte(data), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			var expected bytes.Buffer
+			if err = tp.Execute(&expected, v); err != nil {
+				t.Fatal(err)
+			}
+
+			if out := run(data); out != expected.String() {
+				t.Errorf("invalid output of %s for %s, expected %q and received %q", tc.tmpl, data, expected.String(), out)
+			}
+		}
+	}
+}
+
+// buildCompiled will build a program which renders JSON data of typ with the compiled Template. The
+// program is a module within a temporary directory, which requires this module from its directory
+func buildCompiled(t *testing.T, tp *Template, typ reflect.Type) (run func(data string) string) {
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		t.Skip("go.mod not found, compiled templates cannot be built")
+	}
+
+	co := CompileOptions{Package: "main", PkgPath: importPath, Func: "RenderData"}
+	src, err := Compile(tp, typ, co)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The requirements and replacements of this module are kept, so the program builds with the same dependencies
+	dir := t.TempDir()
+	mod = regexp.MustCompile(`(?m)^module .*$`).ReplaceAll(mod, []byte("module compiled"))
+	mod = fmt.Appendf(mod, "\nrequire %s v0.0.0\n\nreplace %s => %s\n", importPath, importPath, root)
+	files := map[string][]byte{
+		"go.mod":           mod,
+		"data_mustache.go": src,
+		"main.go":          []byte(fmt.Sprintf(compileMain, strings.ReplaceAll(typ.String(), "mustache.", ""))),
+	}
+
+	if sum, err := os.ReadFile(filepath.Join(root, "go.sum")); err == nil {
+		files["go.sum"] = sum
+	}
+
+	for name, b := range files {
+		if err = os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bin := filepath.Join(dir, "compiled")
+	cmd := exec.Command("go", "build", "-mod=mod", "-o", bin, ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("error building compiled template: %v\n%s\n%s", err, out, src)
+	}
+
+	return func(data string) string {
+		cmd := exec.Command(bin)
+		cmd.Stdin = strings.NewReader(data)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("error running compiled template: %v\n%s", err, out)
+		}
+
+		return string(out)
+	}
+}
+
+func TestNodes(t *testing.T) {
+	var (
+		tp  *Template
+		err error
+	)
+
+	tmpl := "<h1>{{ title | upper }}</h1>\n{{# items }}\n  <li>{{{ name }}}</li>\n{{/ items }}{{^ items }}none{{/ items }}"
+	if tp, err = Parse([]byte(tmpl), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	type node struct {
+		kind NodeKind
+		key  string
+		pos  Position
+	}
+
+	var nodes []node
+	Walk(tp.Nodes(), func(n Node) bool {
+		if n.Kind() != NodeText {
+			nodes = append(nodes, node{n.Kind(), n.Key(), n.Pos()})
+		}
+
+		return true
+	})
+
+	exp := []node{
+		{NodeValue, "title", Position{Offset: 4, Line: 1, Column: 5}},
+		{NodeSection, "items", Position{Offset: 29, Line: 2, Column: 1}},
+		{NodeValue, "name", Position{Offset: 48, Line: 3, Column: 7}},
+		{NodeInvertedSection, "items", Position{Offset: 78, Line: 4, Column: 13}},
+	}
+
+	if len(nodes) != len(exp) {
+		t.Fatalf("invalid nodes, expected %v and received %v", exp, nodes)
+	}
+
+	for i, n := range nodes {
+		if n != exp[i] {
+			t.Errorf("invalid node, expected %v and received %v", exp[i], n)
+		}
+	}
+
+	value := tp.Nodes()[1]
+	if !value.Escaped() || len(value.Filters()) != 1 || value.Filters()[0] != "upper" {
+		t.Errorf("invalid value node, expected an escaped value with the upper filter and received %+v", value)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	var (
+		tp  *Template
+		err error
+	)
+
+	tmpl := `Hi {{ name }}{{# orders }}#{{ id }}{{# items }}{{ sku }}{{^ @last }},{{/ @last }}{{/ items }}{{/ orders }}` +
+		`{{^ orders }}No orders{{/ orders }}{{# tags }}{{ . }}{{/ tags }}{{# name }}!{{/ name }}`
+
+	if tp, err = Parse([]byte(tmpl), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Variable{
+		{Path: "name", Key: "name", Usage: UsageScalar | UsageSection},
+		{Path: "orders", Key: "orders", Usage: UsageSection | UsageInvertedSection},
+		{Path: "orders[].id", Key: "id", Usage: UsageScalar},
+		{Path: "orders[].items", Key: "items", Usage: UsageSection},
+		{Path: "orders[].items[].sku", Key: "sku", Usage: UsageScalar},
+		{Path: "tags", Key: "tags", Usage: UsageSection},
+		{Path: "tags[]", Key: ".", Usage: UsageScalar},
+	}
+
+	vs := tp.Variables()
+	if len(vs) != len(exp) {
+		t.Fatalf("invalid variables, expected %v and received %v", exp, vs)
+	}
+
+	for i, v := range vs {
+		if v != exp[i] {
+			t.Errorf("invalid variable, expected %v and received %v", exp[i], v)
+		}
+	}
+
+	// Sections of the current context iterate a list of the context
+	if tp, err = Parse([]byte("{{# . }}<li>{{ name }}</li>{{/ . }}"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	exp = []Variable{{Path: "[].name", Key: "name", Usage: UsageScalar}}
+	if vs = tp.Variables(); !reflect.DeepEqual(vs, exp) {
+		t.Errorf("invalid variables, expected %v and received %v", exp, vs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var (
+		tp  *Template
+		err error
+	)
+
+	tmpl := `{{ name }}{{# orders }}{{ id }}{{# items }}{{ sku }}{{ price }}{{/ items }}{{/ orders }}` +
+		`{{# title }}{{ text }}{{/ title }}{{^ archived }}{{/ archived }}`
+
+	if tp, err = Parse([]byte(tmpl), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"title": "Orders",
+		"orders": []map[string]interface{}{
+			{"id": 1, "items": []map[string]interface{}{{"sku": "a", "price": 1.5}, {"price": 2}}},
+			{"id": 2, "items": []map[string]interface{}{{"sku": "c", "price": struct{}{}}}},
+		},
+	}
+
+	var es ValidationErrors
+	if err = tp.Validate(data); !errors.As(err, &es) {
+		t.Fatalf("invalid error, expected ValidationErrors and received %v", err)
+	}
+
+	exp := []string{
+		"name: missing key",
+		"orders[].items[].sku: missing key",
+		"orders[].items[].price: unsupported type provided",
+		"title: scalar used as section",
+	}
+
+	if len(es) != len(exp) {
+		t.Fatalf("invalid errors, expected %v and received %v", exp, es)
+	}
+
+	for i, e := range es {
+		if e.Error() != exp[i] {
+			t.Errorf("invalid error, expected %q and received %q", exp[i], e.Error())
+		}
+	}
+
+	data["name"] = "Panda"
+	data["title"] = true
+	data["text"] = "Hi"
+	data["orders"] = []interface{}{}
+	if err = tp.Validate(data); err != nil {
+		t.Errorf("invalid error, expected nil and received %v", err)
+	}
+
+	// Data which is valid renders without errors
+	testCases(t, []testCase{
+		{tmpl: tmpl, data: data, expected: "PandaHi"},
+		{tmpl: tmpl, opts: []Option{OnMissingError()}, data: data, expected: "PandaHi"},
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	tcs := []struct {
+		tmpl string
+		err  error
+		pos  [2]int
+	}{
+		{"Hello {{ name ", ErrInvalidSyntax, [2]int{1, 15}},
+		{"<ul>\n  {{# items }}<li>{{ . }}</li>", ErrUnclosedSection, [2]int{2, 3}},
+		{"{{# a }}\n{{# b }}{{/ a }}{{/ b }}", ErrMismatchedSection, [2]int{2, 9}},
+		{"{{# a }}{{# b }}\n{{/ b }}", ErrUnclosedSection, [2]int{1, 1}},
+		{"{{# a }}\n{{^ b }}{{/ b }}{{/ a }}{{/ a }}", ErrMismatchedSection, [2]int{2, 25}},
+		{"{{ name }}{{/ name }}", ErrMismatchedSection, [2]int{1, 11}},
+		{"{{# a }}\n{{ b | unknown }}{{/ a }}", ErrUnknownFilter, [2]int{2, 1}},
+		{"{{ na-me }}", ErrInvalidSyntax, [2]int{1, 6}},
+	}
+
+	for _, tc := range tcs {
+		_, err := Parse([]byte(tc.tmpl), "")
+
+		var pe *ParseError
+		if !errors.As(err, &pe) || !errors.Is(err, tc.err) {
+			t.Errorf("invalid error for %q, expected %v and received %v", tc.tmpl, tc.err, err)
+			continue
+		}
+
+		if pos := [2]int{pe.Line, pe.Column}; pos != tc.pos {
+			t.Errorf("invalid position for %q, expected %v and received %v", tc.tmpl, tc.pos, pos)
+		}
+	}
+
+	// Sections are nested deeper than a byte can count
+	depth := 300
+	tmpl := strings.Repeat("{{# items }}", depth) + "{{ name }}" + strings.Repeat("{{/ items }}", depth)
+	tp, err := Parse([]byte(tmpl), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data interface{} = map[string]interface{}{"name": "Panda"}
+	for i := 0; i < depth; i++ {
+		data = map[string]interface{}{"items": data}
+	}
+
+	var buf bytes.Buffer
+	if err = tp.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "Panda" {
+		t.Fatalf("invalid output, expected %q and received %q", "Panda", buf.String())
+	}
+}
+
+func TestEscape(t *testing.T) {
+	quote := func(b []byte) []byte {
+		return bytes.ReplaceAll(b, []byte(`"`), []byte(`\"`))
+	}
+
+	tp, err := Parse([]byte(`name = "{{ name }}" raw = {{{ name }}}`), "", WithEscape(quote))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = tp.Execute(&buf, map[string]string{"name": `<a "b">`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `name = "<a \"b\">" raw = <a "b">`; buf.String() != expected {
+		t.Fatalf("invalid value, expected %q and received %q", expected, buf.String())
+	}
+}
+
+func TestFormat(t *testing.T) {
+	standalone := []Option{WithStandaloneLines()}
+	nested := "<ul>\n{{#items}}\n<li>{{name}}</li>\n      {{^done}}  \n\tpending\n{{/done}}\n{{/items}}\n</ul>\n"
+	tcs := []struct {
+		tmpl     string
+		opts     []Option
+		expected string
+	}{
+		{"Hello {{name}}{{{  html}}}", nil, "Hello {{ name }}{{{ html }}}"},
+		{"<ul>\n  {{#  items}}<li>{{.}}</li>{{/items  }}\n</ul>", nil, "<ul>\n  {{# items }}<li>{{ . }}</li>{{/ items }}\n</ul>"},
+		{"{{^items}}None{{/ items}}{{#@entries  prices}}{{@key}}{{/@entries prices}}", nil, "{{^ items }}None{{/ items }}{{# @entries prices }}{{ @key }}{{/ @entries prices }}"},
+		{"{{price|currency \"USD\"|custom \"a b\" x}}", nil, "{{ price | currency \"USD\" | custom \"a b\" x }}"},
+		{"{{ title | truncate 8  \"|\" }}", nil, "{{ title | truncate 8 \"|\" }}"},
+		{"{{>header}}{{<layouts/base}}{{$content}}Hi{{/content}}{{/layouts/base}}", nil, "{{> header }}{{< layouts/base }}{{$ content }}Hi{{/ content }}{{/ layouts/base }}"},
+		{"{{_greeting.welcome}} {{_  cart.items   count}}", nil, "{{_ greeting.welcome }} {{_ cart.items count }}"},
+
+		// Standalone section tags are indented when their lines are not rendered
+		{nested, nil, "<ul>\n{{# items }}\n<li>{{ name }}</li>\n      {{^ done }}  \n\tpending\n{{/ done }}\n{{/ items }}\n</ul>\n"},
+		{nested, standalone, "<ul>\n{{# items }}\n<li>{{ name }}</li>\n  {{^ done }}\n\tpending\n  {{/ done }}\n{{/ items }}\n</ul>\n"},
+		{"  {{#a}}\n{{#b}}x{{/b}}\n{{<base}}\r\n{{$c}}\r\n{{/c}}\r\n{{/base}}\n\t{{/a}}", standalone, "  {{# a }}\n{{# b }}x{{/ b }}\n    {{< base }}\r\n      {{$ c }}\r\n      {{/ c }}\r\n    {{/ base }}\n  {{/ a }}"},
+	}
+
+	for _, tc := range tcs {
+		out, err := Format([]byte(tc.tmpl), tc.opts...)
+		if err != nil {
+			t.Fatalf("error formatting %q: %v", tc.tmpl, err)
+		}
+
+		if string(out) != tc.expected {
+			t.Fatalf("invalid format, expected %q and received %q", tc.expected, out)
+		}
+	}
+
+	// Formatting does not change the rendered output
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "done": true},
+			map[string]interface{}{"name": "b"},
+		},
+	}
+
+	srcs := []string{"{{#items}}{{name|upper}} {{^ done}}pending{{/done}}{{name|truncate 0 \"|\"}}\n{{/items}}", nested}
+	for _, src := range srcs {
+		for _, opts := range [][]Option{nil, standalone} {
+			out, err := Format([]byte(src), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected, err := (testCase{tmpl: src, opts: opts, data: data}).run()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testCases(t, []testCase{{tmpl: string(out), opts: opts, data: data, expected: expected}})
+		}
+	}
+
+	if _, err := Format([]byte("{{# items }}")); !errors.Is(err, ErrUnclosedSection) {
+		t.Fatalf("invalid error, expected %v and received %v", ErrUnclosedSection, err)
+	}
+}
+
+func TestStandaloneLines(t *testing.T) {
+	list := "<ul>\n  {{# items }}\n  <li>{{ . }}</li>\n  {{/ items }}\n</ul>"
+	data := map[string]interface{}{"items": []string{"a", "b"}, "name": "Panda"}
+	standalone := []Option{WithStandaloneLines()}
+
+	testCases(t, []testCase{
+		{tmpl: list, opts: standalone, data: data, expected: "<ul>\n  <li>a</li>\n  <li>b</li>\n</ul>"},
+		{tmpl: list, data: data, expected: "<ul>\n  \n  <li>a</li>\n  \n  <li>b</li>\n  \n</ul>"},
+		{tmpl: "{{^ none }}\r\nempty\r\n\t{{/ none }}\r\n", opts: standalone, data: data, expected: "empty\r\n"},
+		{tmpl: "{{# items }}\n{{^ none }}\n{{ . }}\n{{/ none }}\n{{/ items }}", opts: standalone, data: data, expected: "a\nb\n"},
+
+		// Tags which share their line, and values, are not standalone
+		{tmpl: "a {{# name }}b{{/ name }}\n{{# name }}{{/ name }}\n", opts: standalone, data: data, expected: "a b\n\n"},
+		{tmpl: "  {{ name }}\n", opts: standalone, data: data, expected: "  Panda\n"},
+	})
+}
+
+func TestSource(t *testing.T) {
+	src := "Hi {{name|upper}}!\n{{#  items }}<li>{{{ html }}}</li>{{/items}}{{^ items}}None{{/ items }}{{_ cart.items count }}"
+	tp, err := Parse([]byte(src), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(tp.Source()) != src {
+		t.Fatalf("invalid source, expected %q and received %q", src, tp.Source())
+	}
+
+	var raw string
+	for _, n := range tp.Nodes() {
+		raw += n.Raw()
+	}
+
+	if raw != src {
+		t.Fatalf("invalid raw nodes, expected %q and received %q", src, raw)
+	}
+
+	var raws []string
+	Walk(tp.Nodes(), func(n Node) bool {
+		if n.Kind() != NodeText {
+			raws = append(raws, n.Raw())
+		}
+
+		return true
+	})
+
+	expected := []string{
+		"{{name|upper}}",
+		"{{#  items }}<li>{{{ html }}}</li>{{/items}}",
+		"{{{ html }}}",
+		"{{^ items}}None{{/ items }}",
+		"{{_ cart.items count }}",
+	}
+
+	if !reflect.DeepEqual(raws, expected) {
+		t.Fatalf("invalid raw nodes, expected %q and received %q", expected, raws)
+	}
+}
+
+func TestRenderAllocs(t *testing.T) {
+	tp, err := Parse([]byte(exampleStringMapStr), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"name":  "Panda",
+		"count": 42,
+		"items": []map[string]string{{"name": "Apple"}, {"name": "Banana"}},
+	}
+
+	var out string
+	if err = tp.Render(data, func(b []byte) {
+		out = string(b)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "<p>Hello Panda (42)</p><ul><li>1. Apple</li><li>2. Banana</li></ul>"; out != expected {
+		t.Fatalf("invalid output, expected %q and received %q", expected, out)
+	}
+
+	if raceEnabled {
+		t.Skip("pooled states are dropped by the race detector, so renders allocate")
+	}
+
+	for _, d := range []interface{}{data, map[string]string{"name": "Panda", "count": "42"}} {
+		allocs := testing.AllocsPerRun(100, func() {
+			if err := tp.Render(d, func([]byte) {}); err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		if allocs != 0 {
+			t.Fatalf("invalid allocations for %T, expected 0 and received %v", d, allocs)
+		}
+	}
+}
+
+func TestIndexedAficionado(t *testing.T) {
+	s := NewSet()
+	if err := s.Add("item", []byte("<li>{{ @number }}. {{ name }}</li>")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Add("page", []byte("<h1>{{ title }}{{ . }}</h1>{{# items }}{{> item }}{{/ items }}{{^ items }}{{ empty }}{{/ items }}")); err != nil {
+		t.Fatal(err)
+	}
+
+	tp, _ := s.Lookup("page")
+	if expected := []string{"name", "title", "items", "empty"}; !reflect.DeepEqual(tp.Keys(), expected) {
+		t.Fatalf("invalid keys, expected %q and received %q", expected, tp.Keys())
+	}
+
+	if id, ok := tp.KeyID("items"); !ok || id != 2 {
+		t.Fatalf("invalid ID of items, expected 2 and received %d (%v)", id, ok)
+	}
+
+	if _, ok := tp.KeyID("@number"); ok {
+		t.Fatal("invalid ID of @number, expected reserved keys not to have IDs")
+	}
+
+	data := newIndexedData(tp, map[string]interface{}{
+		"title": "Fruit",
+		"items": []Aficionado{
+			newIndexedData(tp, map[string]interface{}{"name": "Apple"}),
+			newIndexedData(tp, map[string]interface{}{"name": "<Banana>"}),
+		},
+	})
+
+	var buf bytes.Buffer
+	if err := tp.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "<h1>Fruit</h1><li>1. Apple</li><li>2. &lt;Banana&gt;</li>"; buf.String() != expected {
+		t.Fatalf("invalid output, expected %q and received %q", expected, buf.String())
+	}
+}
+
+func TestIndexedAficionadoMessages(t *testing.T) {
+	// Messages are parsed as they are rendered, so their keys cannot be added to the keys of the data
+	catalog := MessageCatalog{"": {"greeting": "Hello, {{ name }}{{ suffix }}!"}}
+	tp, err := Parse([]byte("{{_ greeting }} ({{ name }})"), "", WithCatalog(catalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := newIndexedData(tp, map[string]interface{}{"name": "Panda"})
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		if err = tp.Execute(&buf, data); err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := "Hello, Panda! (Panda)"; buf.String() != expected {
+			t.Fatalf("invalid output, expected %q and received %q", expected, buf.String())
+		}
+	}
+
+	if expected := []string{"name"}; !reflect.DeepEqual(tp.Keys(), expected) {
+		t.Fatalf("invalid keys, expected %q and received %q", expected, tp.Keys())
+	}
+}
+
+// indexedData is an IndexedAficionado of values by the IDs of their keys
+type indexedData []interface{}
+
+func newIndexedData(t *Template, m map[string]interface{}) indexedData {
+	d := make(indexedData, len(t.Keys()))
+	for k, v := range m {
+		id, _ := t.KeyID(k)
+		d[id] = v
+	}
+
+	return d
+}
+
+func (d indexedData) MarshalMustache(r *Renderer) error {
+	return errInvalidOutput
+}
+
+func (d indexedData) GetByID(id int) interface{} {
+	return d[id]
+}
+
 func TestLong(t *testing.T) {
 	var err error
 	if err = Render(exampleLong, m, func(b []byte) {
@@ -139,6 +1399,53 @@ func BenchmarkLong(b *testing.B) {
 	benchmark(b, exampleLong, m)
 }
 
+func BenchmarkParseNested8(b *testing.B) {
+	benchmarkParse(b, nestedTemplate(8))
+}
+
+func BenchmarkParseNested64(b *testing.B) {
+	benchmarkParse(b, nestedTemplate(64))
+}
+
+func BenchmarkParseNested512(b *testing.B) {
+	benchmarkParse(b, nestedTemplate(512))
+}
+
+func BenchmarkRenderStringMap(b *testing.B) {
+	benchmark(b, []byte(exampleStringMapStr), map[string]string{"name": "Panda", "count": "42"})
+}
+
+func BenchmarkRenderStringMapList(b *testing.B) {
+	benchmark(b, []byte(exampleStringMapStr), map[string]interface{}{
+		"name":  "Panda",
+		"count": 42,
+		"items": []map[string]string{{"name": "Apple"}, {"name": "Banana"}, {"name": "Cherry"}},
+	})
+}
+
+func benchmarkParse(b *testing.B, tgt []byte) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(tgt, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// nestedTemplate will return a template of sections nested to the provided depth
+func nestedTemplate(depth int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < depth; i++ {
+		buf.WriteString("<div>{{# items }}{{ name }}")
+	}
+
+	for i := 0; i < depth; i++ {
+		buf.WriteString("{{/ items }}</div>")
+	}
+
+	return buf.Bytes()
+}
+
 func benchmark(b *testing.B, tgt []byte, data interface{}) {
 	b.StopTimer()
 	var (
@@ -146,7 +1453,7 @@ func benchmark(b *testing.B, tgt []byte, data interface{}) {
 		err  error
 	)
 
-	if tmpl, err = Parse(tgt); err != nil {
+	if tmpl, err = Parse(tgt, ""); err != nil {
 		b.Error(err)
 		return
 	}
@@ -0,0 +1,89 @@
+package mustache
+
+const (
+	missingIgnore uint8 = iota
+	missingError
+	missingLiteral
+	missingRaw
+	missingFunc
+)
+
+// Option is used to configure a Template at parse time
+type Option func(*options)
+
+// MissingFunc is called with the key of a value which could not be resolved
+type MissingFunc func(key string) interface{}
+
+// OnMissingError will return ErrMissingKey when a value cannot be resolved
+func OnMissingError() Option {
+	return func(o *options) {
+		o.missing = missingError
+	}
+}
+
+// OnMissingLiteral will write the provided placeholder when a value cannot be resolved
+func OnMissingLiteral(placeholder string) Option {
+	return func(o *options) {
+		o.missing = missingLiteral
+		o.placeholder = []byte(placeholder)
+	}
+}
+
+// OnMissingRaw will re-emit the raw tag, including its filters (E.g. {{ key | upper }}), when a value
+// cannot be resolved. This allows a second rendering pass to fill the value
+func OnMissingRaw() Option {
+	return func(o *options) {
+		o.missing = missingRaw
+	}
+}
+
+// OnMissingFunc will render the value returned by fn when a value cannot be resolved
+func OnMissingFunc(fn MissingFunc) Option {
+	return func(o *options) {
+		o.missing = missingFunc
+		o.missingFn = fn
+	}
+}
+
+// EscapeFunc escapes the bytes of a value before it is written
+type EscapeFunc func([]byte) []byte
+
+// WithEscape will escape values (E.g. {{ name }}) with the provided func rather than as HTML.
+// Unescaped values (E.g. {{{ name }}}) are not affected
+func WithEscape(fn EscapeFunc) Option {
+	return func(o *options) {
+		o.escape = fn
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := options{keys: newKeyTable()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &o
+}
+
+// options are shared between a Template and all of it's sub-templates
+type options struct {
+	missing     uint8
+	placeholder []byte
+	missingFn   MissingFunc
+
+	escape EscapeFunc // Escapes values, values are escaped as HTML when nil
+
+	standalone bool // Lines of standalone section tags are not rendered
+
+	filters    Filters
+	anyFilters bool // Unknown filters are parsed without a func, used by Format
+	locale     *Locale
+
+	catalog  Catalog
+	messages messageCache
+
+	limits Limits
+
+	set  *Set      // Set the template belongs to, partials and layouts are resolved by name when set
+	keys *keyTable // IDs of the keys of the template, its partials and Set
+}
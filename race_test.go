@@ -0,0 +1,6 @@
+//go:build race
+
+package mustache
+
+// raceEnabled is true when the race detector is enabled, which randomly drops the items of pools
+const raceEnabled = true
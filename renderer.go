@@ -1,12 +1,18 @@
 package mustache
 
 import (
+	"context"
+	"reflect"
+	"sort"
 	"sync"
 
 	"github.com/itsmontoya/buffer"
 	"github.com/itsmontoya/escapist"
 )
 
+// doneInterval is the number of tokens rendered between checks of a render's context
+const doneInterval = 64
+
 var rp = rendererPool{
 	p: sync.Pool{
 		New: func() interface{} {
@@ -35,19 +41,57 @@ func newRenderer(t *Template, a Aficionado) *Renderer {
 	}
 }
 
-// Renderer helps render
+// Renderer helps render. A Renderer is only valid for the duration of the MarshalMustache call
+// it is provided to, and must not be retained
 type Renderer struct {
-	t     *Template
-	a     Aficionado
-	as    []Aficionado
-	isSet bool
+	t    *Template
+	a    Aficionado
+	as   section
+	loop *loop
+	rs   *renderState
 
 	buf *buffer.Buffer
 	get func(string) interface{}
+	g   getter // Set rather than get by the common Aficionados
+
+	ia IndexedAficionado // Set rather than get when the Aficionado gets values by ID
+}
+
+// getter gets values by key. The common Aficionados render as getters, as the func value of
+// their Get method would be allocated for every call to ForEach
+type getter interface {
+	Get(key string) interface{}
+}
+
+// valueAppender is a getter which appends values to the output without boxing them as an interface{}
+type valueAppender interface {
+	appendValue(dst []byte, key string) (b []byte, ok bool)
+}
+
+// reset clears the Renderer, so it can be returned to the pool. This is called on every return path,
+// including errors, so a pooled Renderer never references a previous render
+func (r *Renderer) reset() {
+	r.t = nil
+	r.a = nil
+	r.as = nil
+	r.loop = nil
+	r.rs = nil
+	r.buf = nil
+	r.get = nil
+	r.g = nil
+	r.ia = nil
 }
 
 func (r *Renderer) render() (err error) {
+	if r.rs.v != nil {
+		return r.validate()
+	}
+
 	for _, tkn := range r.t.tkns {
+		if err = r.rs.checkDone(); err != nil {
+			break
+		}
+
 		switch tt := tkn.(type) {
 		case tmplToken:
 			r.buf.Write(r.t.tmpl[tt.start:tt.end])
@@ -57,11 +101,23 @@ func (r *Renderer) render() (err error) {
 			err = r.processSection(tt)
 		case invertedSectionToken:
 			err = r.processInvertedSection(tt)
+		case messageToken:
+			err = r.processMessage(tt)
+		case partialToken:
+			err = r.processPartial(tt)
+		case parentToken:
+			err = r.processParent(tt)
+		case blockToken:
+			err = r.processBlock(tt)
 		}
 
 		if err != nil {
 			break
 		}
+
+		if err = r.rs.checkOutput(); err != nil {
+			break
+		}
 	}
 
 	return
@@ -69,24 +125,93 @@ func (r *Renderer) render() (err error) {
 
 func (r *Renderer) processValue(tkn valToken) (err error) {
 	if r.a == nil {
+		return r.processMissing(tkn)
+	}
+
+	if va, ok := r.g.(valueAppender); ok && len(tkn.filters) == 0 && tkn.key[0] != charAt {
+		// Values of the common maps are appended to the scratch buffer without being boxed
+		var b []byte
+		if b, ok = va.appendValue(r.rs.scratch[:0], tkn.key); !ok {
+			return r.processMissing(tkn)
+		}
+
+		r.writeScratch(b, tkn.escape)
 		return
 	}
 
-	if b, ok, invalid := getValueBytes(r.get(tkn.key)); invalid {
+	v := r.lookup(tkn.key, tkn.id)
+	if len(tkn.filters) > 0 {
+		if v, err = applyFilters(v, tkn.filters, r.rs.locale); err != nil {
+			return
+		}
+	}
+
+	if r.rs.locale != nil {
+		if b, ok := appendLocaleValueBytes(r.rs.scratch[:0], r.rs.locale, v); ok {
+			r.writeScratch(b, tkn.escape)
+			return
+		}
+	}
+
+	if b, ok, invalid := appendValueBytes(r.rs.scratch[:0], v); invalid {
 		return ErrUnsupportedType
 	} else if !ok {
-		return
+		return r.processMissing(tkn)
 	} else {
-		if tkn.escape {
-			b = escapist.Escape(b)
-		}
+		r.writeScratch(b, tkn.escape)
+	}
+
+	return
+}
 
-		r.buf.Write(b)
+func (r *Renderer) processMissing(tkn valToken) (err error) {
+	o := r.t.o
+	switch o.missing {
+	case missingIgnore:
+	case missingError:
+		err = ErrMissingKey
+	case missingLiteral:
+		r.buf.Write(o.placeholder)
+	case missingRaw:
+		r.writeRawValue(tkn)
+	case missingFunc:
+		if b, ok, invalid := getValueBytes(o.missingFn(tkn.key)); invalid {
+			err = ErrUnsupportedType
+		} else if ok {
+			r.writeValue(b, tkn.escape)
+		}
 	}
 
 	return
 }
 
+func (r *Renderer) writeValue(b []byte, escape bool) {
+	switch {
+	case !escape:
+	case r.t.o.escape != nil:
+		b = r.t.o.escape(b)
+	case needsEscape(b):
+		b = escapist.Escape(b)
+	}
+
+	r.buf.Write(b)
+}
+
+// writeScratch will write a value which was appended to the scratch buffer, and keep the
+// scratch buffer for the next value once it has grown
+func (r *Renderer) writeScratch(b []byte, escape bool) {
+	r.writeValue(b, escape)
+	if cap(b) > cap(r.rs.scratch) {
+		r.rs.scratch = b[:0]
+	}
+}
+
+// writeRawValue will write the source of the tag of a value token (E.g. {{ price | currency "USD" }}),
+// so it can be filled by a second rendering pass
+func (r *Renderer) writeRawValue(tkn valToken) {
+	r.buf.Write(r.t.tmpl[tkn.pos:tkn.end])
+}
+
 func (r *Renderer) processSection(tkn sectionToken) (err error) {
 	var (
 		s       section
@@ -103,10 +228,16 @@ func (r *Renderer) processSection(tkn sectionToken) (err error) {
 		}
 	} else {
 		var v interface{}
-		if tkn.key == "." {
+		if tkn.key == "." && tkn.entries {
+			v = r.a
+		} else if tkn.key == "." {
 			v = r.a != nil
 		} else {
-			v = r.get(tkn.key)
+			v = r.lookup(tkn.key, tkn.id)
+		}
+
+		if tkn.entries {
+			return r.processEntries(tkn, v)
 		}
 
 		if s, ok, invalid = getSection(r.a, v); invalid {
@@ -118,21 +249,179 @@ func (r *Renderer) processSection(tkn sectionToken) (err error) {
 
 	switch st := s.(type) {
 	case Aficionado:
-		err = tkn.t.render(st, r.buf)
-	case []Aficionado:
-		for _, a := range st {
-			err = tkn.t.render(a, r.buf)
-		}
+		err = tkn.t.render(st, r.rs, r.loop)
+	case sequence:
+		err = r.processSequence(tkn, st)
 
 	case nil:
 
 	default:
-		err = ErrUnsupportedType
+		err = r.processList(tkn, st)
 	}
 
 	return
 }
 
+// processList renders a section for each item of a list. Lists are iterated in place, and the items
+// of the common lists are converted to Aficionados as they are rendered
+func (r *Renderer) processList(tkn sectionToken, s section) (err error) {
+	switch st := s.(type) {
+	case []Aficionado:
+		return renderItems(r, tkn, st, func(a Aficionado) Aficionado { return a })
+	case []interface{}:
+		return renderItems(r, tkn, st, getAficionado)
+	case []map[string]interface{}:
+		return renderItems(r, tkn, st, func(m map[string]interface{}) Aficionado { return InterfaceMap(m) })
+	case []map[string]string:
+		return renderItems(r, tkn, st, func(m map[string]string) Aficionado { return StringMap(m) })
+	case []string:
+		return renderItems(r, tkn, st, func(v string) Aficionado { return Value{v} })
+	case []int64:
+		return renderItems(r, tkn, st, func(v int64) Aficionado { return Value{v} })
+	case []int32:
+		return renderItems(r, tkn, st, func(v int32) Aficionado { return Value{v} })
+	case []int:
+		return renderItems(r, tkn, st, func(v int) Aficionado { return Value{v} })
+	case []float64:
+		return renderItems(r, tkn, st, func(v float64) Aficionado { return Value{v} })
+	case []float32:
+		return renderItems(r, tkn, st, func(v float32) Aficionado { return Value{v} })
+	case reflectList:
+		return r.processReflectList(tkn, st.rv)
+	}
+
+	return ErrUnsupportedType
+}
+
+// renderItems renders a section for each item of a list, within the Aficionado returned by fn
+func renderItems[T any](r *Renderer, tkn sectionToken, items []T, fn func(T) Aficionado) (err error) {
+	l := r.rs.newLoop()
+	l.length = len(items)
+	for i, item := range items {
+		if err = r.rs.iterate(); err != nil {
+			break
+		}
+
+		l.index = i
+		l.last = i == len(items)-1
+		if err = tkn.t.render(fn(item), r.rs, l); err != nil {
+			break
+		}
+	}
+
+	r.rs.freeLoop(l)
+	return
+}
+
+// processReflectList renders a section for each item of a list of another type, by indexing it in place
+func (r *Renderer) processReflectList(tkn sectionToken, rv reflect.Value) (err error) {
+	l := r.rs.newLoop()
+	l.length = rv.Len()
+	for i := 0; i < l.length; i++ {
+		if err = r.rs.iterate(); err != nil {
+			break
+		}
+
+		l.index = i
+		l.last = i == l.length-1
+		if err = tkn.t.render(getAficionado(rv.Index(i).Interface()), r.rs, l); err != nil {
+			break
+		}
+	}
+
+	r.rs.freeLoop(l)
+	return
+}
+
+// processSequence renders a streamed section. Each item is held back until the next item
+// is received, so @last can be determined without knowing the length of the sequence
+func (r *Renderer) processSequence(tkn sectionToken, seq sequence) (err error) {
+	var (
+		prev Aficionado
+		has  bool
+	)
+
+	l := loop{index: -1, length: -1}
+	seq(r.rs.done, func(a Aficionado) bool {
+		if has {
+			if err = r.rs.iterate(); err != nil {
+				return false
+			}
+
+			l.index++
+			if err = tkn.t.render(prev, r.rs, &l); err != nil {
+				return false
+			}
+		}
+
+		prev = a
+		has = true
+		return true
+	})
+
+	if err != nil {
+		return
+	}
+
+	// Channel sequences stop once the context is done, so the final item is not rendered
+	if err = r.rs.contextErr(); err != nil || !has {
+		return
+	}
+
+	if err = r.rs.iterate(); err != nil {
+		return
+	}
+
+	l.index++
+	l.last = true
+	return tkn.t.render(prev, r.rs, &l)
+}
+
+// processEntries renders a section once for each key/value pair of a map, in sorted key order.
+// The value is used as the context and the pair is available through @key and @value
+func (r *Renderer) processEntries(tkn sectionToken, v interface{}) (err error) {
+	rv := reflect.ValueOf(v)
+	if m, ok := v.(reflectMap); ok {
+		rv = m.rv
+	}
+
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+
+	switch {
+	case !rv.IsValid():
+		return
+	case rv.Kind() != reflect.Map, rv.Type().Key().Kind() != reflect.String:
+		return ErrUnsupportedType
+	}
+
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	l := r.rs.newLoop()
+	l.length = len(keys)
+	l.entry = true
+	for i, k := range keys {
+		if err = r.rs.iterate(); err != nil {
+			break
+		}
+
+		l.index = i
+		l.last = i == len(keys)-1
+		l.key = k.String()
+		l.value = rv.MapIndex(k).Interface()
+		if err = tkn.t.render(getAficionado(l.value), r.rs, l); err != nil {
+			break
+		}
+	}
+
+	r.rs.freeLoop(l)
+	return
+}
+
 func (r *Renderer) processInvertedSection(tkn invertedSectionToken) (err error) {
 	var (
 		v       interface{}
@@ -145,7 +434,7 @@ func (r *Renderer) processInvertedSection(tkn invertedSectionToken) (err error)
 		if tkn.key == "." {
 			v = r.a != nil
 		} else {
-			v = r.get(tkn.key)
+			v = r.lookup(tkn.key, tkn.id)
 		}
 	} else {
 		if tkn.key != "." {
@@ -164,9 +453,9 @@ func (r *Renderer) processInvertedSection(tkn invertedSectionToken) (err error)
 
 	switch st := s.(type) {
 	case Aficionado:
-		err = tkn.t.render(st, r.buf)
+		err = tkn.t.render(st, r.rs, r.loop)
 	case []Aficionado, nil:
-		err = tkn.t.renderList(nil, r.buf)
+		err = tkn.t.renderList(nil, r.rs, r.loop)
 		//	case nil:
 
 	default:
@@ -176,9 +465,166 @@ func (r *Renderer) processInvertedSection(tkn invertedSectionToken) (err error)
 	return
 }
 
+// processMessage renders a translated message from the Catalog within the current context
+func (r *Renderer) processMessage(tkn messageToken) (err error) {
+	o := r.t.o
+	if o.catalog == nil {
+		return ErrNoCatalog
+	}
+
+	n := -1
+	if len(tkn.count) > 0 {
+		if n, err = r.messageCount(tkn); err != nil {
+			return
+		}
+	}
+
+	var locale string
+	if r.rs.locale != nil {
+		locale = r.rs.locale.Name
+	}
+
+	msg, ok := o.catalog.Message(locale, tkn.id, n)
+	if !ok {
+		if o.missing == missingError {
+			return ErrMissingMessage
+		}
+
+		r.writeValue([]byte(tkn.id), true)
+		return
+	}
+
+	var mt *Template
+	if mt, err = o.messages.get(msg, o); err != nil {
+		return
+	}
+
+	return r.renderContext(mt)
+}
+
+// messageCount will return the count of a pluralized message. A missing count returns ErrMissingKey
+// with OnMissingError, is provided by the MissingFunc with OnMissingFunc, and is 0 otherwise
+func (r *Renderer) messageCount(tkn messageToken) (n int, err error) {
+	var v interface{}
+	if r.a != nil {
+		v = r.lookup(tkn.count, tkn.countID)
+	}
+
+	if v == nil {
+		switch r.t.o.missing {
+		case missingError:
+			return 0, ErrMissingKey
+		case missingFunc:
+			v = r.t.o.missingFn(tkn.count)
+		}
+	}
+
+	if v == nil {
+		return
+	}
+
+	var f float64
+	if f, err = filterFloat(v); err != nil {
+		return
+	}
+
+	return int(f), nil
+}
+
+func (r *Renderer) processPartial(tkn partialToken) (err error) {
+	var t *Template
+	if t, err = r.t.o.set.get(tkn.name); err != nil {
+		return
+	}
+
+	return r.renderPartial(t)
+}
+
+// processParent renders a layout, with the blocks of the parent tag overriding the layout's blocks
+func (r *Renderer) processParent(tkn parentToken) (err error) {
+	layout := tkn.layout
+	if layout == nil {
+		if layout, err = r.t.o.set.get(tkn.name); err != nil {
+			return
+		}
+	}
+
+	prev := r.rs.blocks
+	blocks := make(map[string]*Template, len(prev))
+	for _, tkn := range tkn.t.tkns {
+		if bt, ok := tkn.(blockToken); ok {
+			blocks[bt.name] = bt.t
+		}
+	}
+
+	// Blocks of outer parent tags take precedence
+	for name, t := range prev {
+		blocks[name] = t
+	}
+
+	r.rs.blocks = blocks
+	err = r.renderPartial(layout)
+	r.rs.blocks = prev
+	return
+}
+
+func (r *Renderer) processBlock(tkn blockToken) (err error) {
+	if t, ok := r.rs.blocks[tkn.name]; ok {
+		return r.renderContext(t)
+	}
+
+	return r.renderContext(tkn.t)
+}
+
+// renderContext will render a template within the current context
+func (r *Renderer) renderContext(t *Template) (err error) {
+	if r.a == nil {
+		return t.renderList(r.as, r.rs, r.loop)
+	}
+
+	return t.render(r.a, r.rs, r.loop)
+}
+
+// renderPartial will render a partial or layout within the current context
+func (r *Renderer) renderPartial(t *Template) (err error) {
+	if r.rs.partials++; exceeds(r.rs.limits.maxPartialDepth(), r.rs.partials) {
+		err = ErrPartialDepthExceeded
+	} else {
+		err = r.renderContext(t)
+	}
+
+	r.rs.partials--
+	return
+}
+
+// lookup will get a value by key, or by the ID of the key for an IndexedAficionado. Reserved keys
+// (prefixed with @) are resolved by the Renderer and are never passed to the get func
+func (r *Renderer) lookup(key string, id int) (v interface{}) {
+	if len(key) == 0 || key[0] != charAt {
+		switch {
+		case r.ia != nil && id == noKey:
+			// The current context (E.g. {{ . }}) and keys which are only used by Catalog messages have
+			// no ID, so they are missing for an IndexedAficionado
+			return nil
+		case r.ia != nil:
+			return r.ia.GetByID(id)
+		case r.g != nil:
+			return r.g.Get(key)
+		}
+
+		return r.get(key)
+	}
+
+	if r.loop != nil {
+		v = r.loop.get(key)
+	}
+
+	return
+}
+
 // ForEach takes in a get func
 func (r *Renderer) ForEach(fn func(string) interface{}) (err error) {
-	if r.get != nil {
+	if r.get != nil || r.g != nil {
 		return ErrForEachSet
 	}
 
@@ -186,4 +632,125 @@ func (r *Renderer) ForEach(fn func(string) interface{}) (err error) {
 	return r.render()
 }
 
+// forEach is ForEach for the common Aficionados, which get their own values
+func (r *Renderer) forEach(g getter) (err error) {
+	if r.get != nil || r.g != nil {
+		return ErrForEachSet
+	}
+
+	r.g = g
+	return r.render()
+}
+
+// Locale will return the Locale of the current render, nil when no Locale has been set
+func (r *Renderer) Locale() *Locale {
+	return r.rs.locale
+}
+
 type section interface{}
+
+// renderState is shared by all of the Renderers of a single render
+type renderState struct {
+	buf    *buffer.Buffer
+	locale *Locale
+	blocks map[string]*Template // Block overrides of the current layout
+
+	ctx   context.Context
+	done  <-chan struct{} // Nil when the render cannot be cancelled
+	ticks int
+
+	v *validator // Set when the render is validating data, no output is produced
+
+	limits     *Limits
+	depth      int // Number of templates being rendered, the root template is depth 1
+	partials   int // Number of partials and layouts being rendered
+	iterations int
+
+	// States are pooled, so the scratch buffer and free loops are reused by later renders
+	scratch []byte  // Values are appended to the scratch buffer before they are escaped and written
+	loops   []*loop // Loops which are free to be reused
+}
+
+// renderStates are the states of in-progress renders
+var renderStates = sync.Pool{
+	New: func() interface{} {
+		return &renderState{}
+	},
+}
+
+// reset will replace the state with next, keeping the scratch buffer and free loops for reuse
+func (rs *renderState) reset(next renderState) {
+	scratch, loops := rs.scratch[:0], rs.loops
+	*rs = next
+	rs.scratch, rs.loops = scratch, loops
+}
+
+// newLoop will return a loop, which is returned with freeLoop once its section is rendered
+func (rs *renderState) newLoop() (l *loop) {
+	n := len(rs.loops)
+	if n == 0 {
+		return &loop{}
+	}
+
+	l = rs.loops[n-1]
+	rs.loops = rs.loops[:n-1]
+	return
+}
+
+// freeLoop will clear a loop, so it does not reference the data of the render, and keep it for reuse
+func (rs *renderState) freeLoop(l *loop) {
+	*l = loop{}
+	rs.loops = append(rs.loops, l)
+}
+
+// enter will increase the depth of the render for a (sub-)template
+func (rs *renderState) enter() (err error) {
+	// The root template is not a level of section nesting
+	if rs.depth++; exceeds(rs.limits.MaxDepth, rs.depth-1) {
+		err = ErrDepthExceeded
+	}
+
+	return
+}
+
+// iterate will count an iteration of a section
+func (rs *renderState) iterate() (err error) {
+	if rs.iterations++; exceeds(rs.limits.MaxIterations, rs.iterations) {
+		err = ErrIterationsExceeded
+	}
+
+	return
+}
+
+// checkOutput will return ErrOutputTooLarge once the output has exceeded the limit
+func (rs *renderState) checkOutput() (err error) {
+	if exceeds(rs.limits.MaxOutputBytes, len(rs.buf.Bytes())) {
+		err = ErrOutputTooLarge
+	}
+
+	return
+}
+
+// checkDone will return the context's error once the context is done. The context is only
+// checked every doneInterval tokens, as checking a channel for every token is noticeably slower
+func (rs *renderState) checkDone() error {
+	if rs.done == nil {
+		return nil
+	}
+
+	if rs.ticks++; rs.ticks%doneInterval != 0 {
+		return nil
+	}
+
+	return rs.contextErr()
+}
+
+// contextErr will return the context's error when the context is done
+func (rs *renderState) contextErr() error {
+	select {
+	case <-rs.done:
+		return rs.ctx.Err()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,164 @@
+package mustache
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/missionMeteora/toolkit/errors"
+)
+
+// ErrTemplateNotFound is returned when a template cannot be found within a Set
+const ErrTemplateNotFound = errors.Error("template not found")
+
+// NewSet will return a new, empty Set. The provided options are shared by every template of the Set
+func NewSet(opts ...Option) *Set {
+	s := Set{
+		ts:   make(map[string]*Template),
+		errs: make(map[string]error),
+		o:    newOptions(opts),
+	}
+
+	s.o.set = &s
+	return &s
+}
+
+// ParseDir will return a Set of the templates within a directory
+func ParseDir(dir string, exts []string, opts ...Option) (s *Set, err error) {
+	return ParseFS(os.DirFS(dir), exts, opts...)
+}
+
+// ParseFS will return a Set of the templates within a filesystem
+func ParseFS(fsys fs.FS, exts []string, opts ...Option) (s *Set, err error) {
+	s = NewSet(opts...)
+	if err = s.ParseFS(fsys, exts); err != nil {
+		s = nil
+	}
+
+	return
+}
+
+// Set is a collection of named templates. Templates of a Set reference each other by name as
+// partials (E.g. {{> emails/header }}) and layouts (E.g. {{< layouts/base }}...{{/ layouts/base }})
+type Set struct {
+	mux  sync.RWMutex
+	ts   map[string]*Template
+	errs map[string]error // Parse errors from reloading, returned when the template is rendered
+	srcs []*source        // Filesystems the Set was parsed from, used for reloading
+	o    *options
+
+	rmux sync.Mutex // Serializes reloads
+}
+
+// Add will parse a template and add it to the Set. An existing template with the same name is replaced
+func (s *Set) Add(name string, tmpl []byte) (err error) {
+	var t *Template
+	if t, err = parseTemplate(tmpl, "", s.o); err != nil {
+		return
+	}
+
+	t.bp = newBufferPool(len(tmpl))
+
+	s.mux.Lock()
+	s.ts[name] = t
+	delete(s.errs, name)
+	s.mux.Unlock()
+	return
+}
+
+// ParseFS will add every file within a filesystem to the Set. Only files with the provided extensions
+// (E.g. ".mustache") are added, every file is added when no extensions are provided.
+// Templates are named by their slash-separated path with the extension removed (E.g. emails/welcome)
+func (s *Set) ParseFS(fsys fs.FS, exts []string) (err error) {
+	src := newSource(fsys, exts)
+	if err = src.walk(func(fp string, st fileStamp) error {
+		tmpl, err := fs.ReadFile(fsys, fp)
+		if err != nil {
+			return err
+		}
+
+		src.files[fp] = st
+		return s.Add(templateName(fp), tmpl)
+	}); err != nil {
+		return
+	}
+
+	s.mux.Lock()
+	s.srcs = append(s.srcs, src)
+	s.mux.Unlock()
+	return
+}
+
+// Lookup will get a template by name
+func (s *Set) Lookup(name string) (t *Template, ok bool) {
+	s.mux.RLock()
+	t, ok = s.ts[name]
+	s.mux.RUnlock()
+	return
+}
+
+// get will get a template by name, returning the parse error from the last reload when the template is invalid
+func (s *Set) get(name string) (t *Template, err error) {
+	var ok bool
+	s.mux.RLock()
+	if err = s.errs[name]; err == nil {
+		if t, ok = s.ts[name]; !ok {
+			err = ErrTemplateNotFound
+		}
+	}
+	s.mux.RUnlock()
+	return
+}
+
+// Names will return the names of the templates within the Set, sorted
+func (s *Set) Names() (names []string) {
+	s.mux.RLock()
+	names = make([]string, 0, len(s.ts))
+	for name := range s.ts {
+		names = append(names, name)
+	}
+	s.mux.RUnlock()
+
+	sort.Strings(names)
+	return
+}
+
+// Execute will render a template by name with the provided data to a writer
+func (s *Set) Execute(w io.Writer, name string, data interface{}) (err error) {
+	return s.ExecuteContext(context.Background(), w, name, data)
+}
+
+// ExecuteContext will render a template by name with the provided data to a writer. Rendering is
+// stopped and the context's error is returned once the context is done
+func (s *Set) ExecuteContext(ctx context.Context, w io.Writer, name string, data interface{}) (err error) {
+	var t *Template
+	if t, err = s.get(name); err != nil {
+		return
+	}
+
+	return t.ExecuteContext(ctx, w, data)
+}
+
+func hasExt(fp string, exts []string) bool {
+	if len(exts) == 0 {
+		return true
+	}
+
+	ext := path.Ext(fp)
+	for _, e := range exts {
+		if e == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+func templateName(fp string) string {
+	return strings.TrimSuffix(fp, path.Ext(fp))
+}
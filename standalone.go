@@ -0,0 +1,121 @@
+package mustache
+
+import "sort"
+
+// WithStandaloneLines will remove the lines of standalone section, inverted section, parent and block tags
+// from the output, as the mustache spec does. A tag is standalone when it is the only content of its line
+// (E.g. "  {{# items }}\n"), the whitespace before the tag and the line break after it are not rendered.
+// This allows sections to be indented (See Format) without changing the output
+func WithStandaloneLines() Option {
+	return func(o *options) {
+		o.standalone = true
+	}
+}
+
+// standaloneLine will return the line of a tag when the tag is standalone. start is the start of the line,
+// and end is the end of the line including its line break
+func standaloneLine(src []byte, pos, tagEnd int) (start, end int, ok bool) {
+	for start = pos; start > 0 && isBlank(src[start-1]); start-- {
+	}
+
+	if start > 0 && src[start-1] != charNewline {
+		return
+	}
+
+	for end = tagEnd; end < len(src) && isBlank(src[end]); end++ {
+	}
+
+	switch {
+	case end == len(src):
+	case src[end] == charNewline:
+		end++
+	case src[end] == '\r' && end+1 < len(src) && src[end+1] == charNewline:
+		end += 2
+	default:
+		return
+	}
+
+	return start, end, true
+}
+
+func isBlank(b byte) bool {
+	return b == charSpace || b == charTab
+}
+
+// stripStandalone will remove the lines of standalone tags from the text of a template
+func stripStandalone(src []byte, tkns tokens) {
+	var strip [][2]int // Ranges of the source which are not rendered, in order
+	walkSectionTags(tkns, 0, func(pos, end int) {
+		if start, lineEnd, ok := standaloneLine(src, pos, end); ok {
+			strip = append(strip, [2]int{start, pos}, [2]int{end, lineEnd})
+		}
+	})
+
+	if len(strip) > 0 {
+		trimText(tkns, 0, strip)
+	}
+}
+
+// walkSectionTags will call fn with the source offsets of the opening and closing tags of each section,
+// inverted section, parent and block, in order. base is the offset of the tokens within the source
+func walkSectionTags(tkns tokens, base int, fn func(pos, end int)) {
+	for _, tkn := range tkns {
+		var (
+			t        *Template
+			pos, end int
+		)
+
+		switch tt := tkn.(type) {
+		case sectionToken:
+			if len(tt.partial) > 0 {
+				// Inlined partials are parsed from their own source
+				continue
+			}
+
+			t, pos, end = tt.t, tt.pos, tt.end
+		case invertedSectionToken:
+			t, pos, end = tt.t, tt.pos, tt.end
+		case parentToken:
+			t, pos, end = tt.t, tt.pos, tt.end
+		case blockToken:
+			t, pos, end = tt.t, tt.pos, tt.end
+		default:
+			continue
+		}
+
+		fn(base+pos, t.offset)
+		walkSectionTags(t.tkns, t.offset, fn)
+		fn(t.offset+len(t.tmpl), base+end)
+	}
+}
+
+// trimText will remove the ranges of the source from the text tokens which overlap them
+func trimText(tkns tokens, base int, strip [][2]int) {
+	for i, tkn := range tkns {
+		switch tt := tkn.(type) {
+		case tmplToken:
+			start, end := base+tt.start, base+tt.end
+			idx := sort.Search(len(strip), func(i int) bool { return strip[i][1] > start })
+			for ; idx < len(strip) && strip[idx][0] < end; idx++ {
+				// Ranges adjoin tags, so they only overlap the start or end of text
+				if s := strip[idx]; s[0] <= start {
+					start = min(s[1], end)
+				} else {
+					end = s[0]
+				}
+			}
+
+			tkns[i] = tmplToken{start: start - base, end: end - base}
+		case sectionToken:
+			if len(tt.partial) == 0 {
+				trimText(tt.t.tkns, tt.t.offset, strip)
+			}
+		case invertedSectionToken:
+			trimText(tt.t.tkns, tt.t.offset, strip)
+		case parentToken:
+			trimText(tt.t.tkns, tt.t.offset, strip)
+		case blockToken:
+			trimText(tt.t.tkns, tt.t.offset, strip)
+		}
+	}
+}
@@ -1,37 +1,82 @@
 package mustache
 
-import "github.com/itsmontoya/buffer"
+import (
+	"context"
+	"io"
 
-func newTemplate(tmpl []byte, tkns tokens) *Template {
+	"github.com/itsmontoya/buffer"
+)
+
+func newTemplate(tmpl []byte, tkns tokens, o *options) *Template {
 	return &Template{
 		tmpl: tmpl,
 		tkns: tkns,
+		o:    o,
 		bp:   bp,
 	}
 }
 
-// Template is a parsed template
+// Template is a parsed template. A Template is immutable once parsed, and is safe to render
+// concurrently from multiple goroutines
 type Template struct {
-	tmpl []byte
-	tkns tokens
+	tmpl   []byte
+	tkns   tokens
+	o      *options
+	offset int // Offset of tmpl within the source of the root template
 
 	bp *buffer.Pool
 }
 
-func (t *Template) setBaseLen(baseLen int) {
-	if baseLen == -1 {
-		baseLen = len(t.tmpl)
+// newBufferPool will return a buffer pool sized for the output of a template
+func newBufferPool(baseLen int) *buffer.Pool {
+	if v := baseLen * 130 / 100; v >= 32 {
+		return buffer.NewPool(v)
 	}
 
-	if v := baseLen * 130 / 100; v < 32 {
-		t.bp = bp
-	} else {
-		t.bp = buffer.NewPool(v)
-	}
+	return bp
 }
 
-// Render will render a template with the provided data
+// Render will render a template with the provided data. The bytes provided to fn are only valid
+// until fn returns, and fn is not called when an error is encountered
 func (t *Template) Render(data interface{}, fn func([]byte)) (err error) {
+	return t.RenderLocale(data, t.o.locale, fn)
+}
+
+// RenderLocale will render a template with the provided data, formatting numbers and dates for the provided Locale
+func (t *Template) RenderLocale(data interface{}, loc *Locale, fn func([]byte)) (err error) {
+	return t.renderData(data, renderState{locale: loc}, fn)
+}
+
+// Execute will render a template with the provided data to a writer
+func (t *Template) Execute(w io.Writer, data interface{}) (err error) {
+	return t.ExecuteContext(context.Background(), w, data)
+}
+
+// ExecuteContext will render a template with the provided data to a writer. Rendering is stopped and
+// the context's error is returned once the context is done, including while waiting to receive from
+// a channel section. Iterators and iter.Seq funcs are not interrupted while they block
+func (t *Template) ExecuteContext(ctx context.Context, w io.Writer, data interface{}) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	rs := renderState{
+		locale: t.o.locale,
+		ctx:    ctx,
+		done:   ctx.Done(),
+	}
+
+	var werr error
+	if err = t.renderData(data, rs, func(b []byte) {
+		_, werr = w.Write(b)
+	}); err != nil {
+		return
+	}
+
+	return werr
+}
+
+func (t *Template) renderData(data interface{}, rs renderState, fn func([]byte)) (err error) {
 	var (
 		s       section
 		ok      bool
@@ -41,68 +86,76 @@ func (t *Template) Render(data interface{}, fn func([]byte)) (err error) {
 	if s, ok, invalid = getSection(nil, data); invalid {
 		return ErrUnsupportedType
 	} else if !ok {
-		if data != nil {
-			return
-		}
-
+		// Falsey data is rendered without a context, so inverted sections are still rendered
 		s = nil
 	}
 
-	buf := t.bp.Get()
+	prs := renderStates.Get().(*renderState)
+	prs.reset(rs)
+	prs.buf = t.bp.Get()
+	prs.limits = &t.o.limits
 
 	switch st := s.(type) {
 	case Aficionado:
-		err = t.render(st, buf)
+		err = t.render(st, prs, nil)
 	case nil:
-		err = t.renderList(nil, buf)
-	case []Aficionado:
-		err = t.renderList(st, buf)
+		err = t.renderList(nil, prs, nil)
 	default:
-		err = ErrUnsupportedType
-		goto END
+		// Lists and sequences are rendered by the sections of the template (E.g. {{# . }})
+		err = t.renderList(st, prs, nil)
 	}
 
-	fn(buf.Bytes())
+	if err == nil {
+		fn(prs.buf.Bytes())
+	}
 
-END:
-	t.bp.Put(buf)
+	t.bp.Put(prs.buf)
+	prs.reset(renderState{})
+	renderStates.Put(prs)
 	return
 }
 
 // Render will render a template with the provided data
-func (t *Template) render(a Aficionado, buf *buffer.Buffer) (err error) {
+func (t *Template) render(a Aficionado, rs *renderState, l *loop) (err error) {
 	r := rp.Get()
 	r.t = t
-	r.buf = buf
+	r.rs = rs
+	r.buf = rs.buf
 	r.a = a
-
-	if err = a.MarshalMustache(r); err != nil {
-		return
+	r.loop = l
+
+	if err = rs.enter(); err == nil {
+		if ia, ok := a.(IndexedAficionado); ok {
+			r.ia = ia
+			err = r.render()
+		} else {
+			err = a.MarshalMustache(r)
+		}
 	}
 
-	r.t = nil
-	r.buf = nil
-	r.a = nil
-	r.get = nil
+	rs.depth--
 
+	r.reset()
 	rp.Put(r)
 	return
 }
 
 // Render will render a template with the provided data
-func (t *Template) renderList(as []Aficionado, buf *buffer.Buffer) (err error) {
+func (t *Template) renderList(as section, rs *renderState, l *loop) (err error) {
 	r := rp.Get()
 	r.t = t
-	r.buf = buf
+	r.rs = rs
+	r.buf = rs.buf
 	r.as = as
+	r.loop = l
 
-	r.render()
+	if err = rs.enter(); err == nil {
+		err = r.render()
+	}
 
-	r.t = nil
-	r.buf = nil
-	r.as = nil
-	r.get = nil
+	rs.depth--
 
+	r.reset()
 	rp.Put(r)
 	return
 }
@@ -2,6 +2,8 @@ package mustache
 
 import ()
 
+// tokens are the parsed tags and text of a template. The pos and end of a token are the offsets of the
+// opening braces of its tag and of the byte following its tag (or closing tag) within the template
 type tokens []token
 type token interface{}
 
@@ -11,16 +13,58 @@ type tmplToken struct {
 }
 
 type valToken struct {
-	key    string
-	escape bool
+	key     string
+	id      int // ID of the key, noKey for reserved keys
+	escape  bool
+	filters []filterCall
+	pos     int
+	end     int
 }
 
 type sectionToken struct {
 	key string
+	id  int
 	t   *Template
+
+	entries bool   // Iterate the key/value pairs of a map
+	partial string // Name of the partial, when the section is an inlined partial
+	pos     int
+	end     int
 }
 
 type invertedSectionToken struct {
 	key string
+	id  int
 	t   *Template
+	pos int
+	end int
+}
+
+type messageToken struct {
+	id      string
+	count   string // Key of the value used to select a plural form
+	countID int
+	pos     int
+	end     int
+}
+
+type partialToken struct {
+	name string
+	pos  int
+	end  int
+}
+
+type parentToken struct {
+	name   string
+	t      *Template // Block overrides
+	layout *Template // Nil when resolved by name from a Set
+	pos    int
+	end    int
+}
+
+type blockToken struct {
+	name string
+	t    *Template // Default content
+	pos  int
+	end  int
 }
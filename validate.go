@@ -0,0 +1,303 @@
+package mustache
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/missionMeteora/toolkit/errors"
+)
+
+// ErrScalarSection is returned by Validate when a scalar is used as a section which references keys
+const ErrScalarSection = errors.Error("scalar used as section")
+
+// ValidationError is a problem found by Validate
+type ValidationError struct {
+	Path string // Path of the key, E.g. orders[].items[].sku
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap will return the cause of the error
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors are the problems found by Validate
+type ValidationErrors []*ValidationError
+
+func (es ValidationErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Validate will walk the Template against the provided data without producing output. Every problem is
+// returned as ValidationErrors: missing keys, scalars used as sections and values of unsupported types.
+// Only the sections which would be rendered for the data are walked, and streamed sections (E.g. channels)
+// are not walked, as walking would consume them. Each problem is reported once per path
+func (t *Template) Validate(data interface{}) (err error) {
+	v := validator{seen: make(map[string]struct{})}
+	if err = t.renderData(data, renderState{locale: t.o.locale, v: &v}, func([]byte) {}); err != nil {
+		return
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+
+	return
+}
+
+// validator collects the problems of a render in validation mode
+type validator struct {
+	path string // Path of the current context
+	errs ValidationErrors
+	seen map[string]struct{}
+}
+
+func (v *validator) add(key string, err error) {
+	path := keyPath(v.path, key)
+	if len(path) == 0 {
+		path = "."
+	}
+
+	id := path + "\x00" + err.Error()
+	if _, ok := v.seen[id]; ok {
+		return
+	}
+
+	v.seen[id] = struct{}{}
+	v.errs = append(v.errs, &ValidationError{Path: path, Err: err})
+}
+
+// validate will walk the tokens of the Renderer's template. Problems are recorded by the validator,
+// and only errors which stop a render (E.g. a cancelled context) are returned
+func (r *Renderer) validate() (err error) {
+	for _, tkn := range r.t.tkns {
+		if err = r.rs.checkDone(); err != nil {
+			break
+		}
+
+		switch tt := tkn.(type) {
+		case valToken:
+			r.validateValue(tt)
+		case sectionToken:
+			err = r.validateSection(tt)
+		case invertedSectionToken:
+			err = r.validateInvertedSection(tt)
+		case messageToken:
+			err = r.validateMessage(tt)
+		case partialToken:
+			if _, perr := r.t.o.set.get(tt.name); perr != nil {
+				r.rs.v.add(tt.name, perr)
+			} else {
+				err = r.processPartial(tt)
+			}
+		case parentToken:
+			if tt.layout != nil {
+				err = r.processParent(tt)
+			} else if _, perr := r.t.o.set.get(tt.name); perr != nil {
+				r.rs.v.add(tt.name, perr)
+			} else {
+				err = r.processParent(tt)
+			}
+		case blockToken:
+			err = r.processBlock(tt)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	return
+}
+
+func (r *Renderer) validateValue(tkn valToken) {
+	var (
+		v   interface{}
+		err error
+	)
+
+	if r.a != nil {
+		v = r.lookup(tkn.key, tkn.id)
+	}
+
+	if len(tkn.filters) > 0 {
+		if v, err = applyFilters(v, tkn.filters, r.rs.locale); err != nil {
+			r.rs.v.add(tkn.key, err)
+			return
+		}
+	}
+
+	if r.rs.locale != nil {
+		if _, ok := appendLocaleValueBytes(nil, r.rs.locale, v); ok {
+			return
+		}
+	}
+
+	if _, ok, invalid := getValueBytes(v); invalid {
+		r.rs.v.add(tkn.key, ErrUnsupportedType)
+	} else if !ok {
+		r.rs.v.add(tkn.key, ErrMissingKey)
+	}
+}
+
+func (r *Renderer) validateSection(tkn sectionToken) (err error) {
+	if r.a == nil || (tkn.key == "." && !tkn.entries) {
+		// Lists and the current context are walked as they are rendered
+		if r.a == nil && tkn.key != "." {
+			r.rs.v.add(tkn.key, ErrUnsupportedType)
+			return
+		}
+
+		return r.processSection(tkn)
+	}
+
+	var v interface{}
+	if tkn.key == "." {
+		v = r.a
+	} else {
+		v = r.lookup(tkn.key, tkn.id)
+	}
+
+	if v == nil {
+		r.rs.v.add(tkn.key, ErrMissingKey)
+		return
+	}
+
+	if !tkn.entries {
+		// Entries are validated by processEntries, which returns ErrUnsupportedType for values which are not maps
+		s, ok, invalid := getSection(r.a, v)
+		switch s.(type) {
+		case Value:
+			if usesContext(tkn.t) {
+				r.rs.v.add(tkn.key, ErrScalarSection)
+				return
+			}
+		case sequence:
+			// Streamed sections are not walked, as walking would consume them
+			return
+		}
+
+		switch {
+		case invalid:
+			r.rs.v.add(tkn.key, ErrUnsupportedType)
+			return
+		case !ok:
+			return
+		case isBool(v):
+			// Boolean sections are rendered within the current context
+			return r.processSection(tkn)
+		}
+	}
+
+	prev := r.rs.v.path
+	r.rs.v.path = sectionPath(prev, tkn.key)
+	if err = r.processSection(tkn); err == ErrUnsupportedType {
+		r.rs.v.path = prev
+		r.rs.v.add(tkn.key, err)
+		err = nil
+	}
+
+	r.rs.v.path = prev
+	return
+}
+
+func (r *Renderer) validateInvertedSection(tkn invertedSectionToken) (err error) {
+	if r.a == nil && tkn.key != "." {
+		r.rs.v.add(tkn.key, ErrUnsupportedType)
+		return
+	}
+
+	if r.a != nil && tkn.key != "." {
+		if _, _, invalid := getInvertedSection(r.a, r.lookup(tkn.key, tkn.id)); invalid {
+			r.rs.v.add(tkn.key, ErrUnsupportedType)
+			return
+		}
+	}
+
+	// Missing keys are not reported, as inverted sections are rendered for missing values
+	return r.processInvertedSection(tkn)
+}
+
+func (r *Renderer) validateMessage(tkn messageToken) (err error) {
+	o := r.t.o
+	if o.catalog == nil {
+		r.rs.v.add(tkn.id, ErrNoCatalog)
+		return
+	}
+
+	n := -1
+	if len(tkn.count) > 0 {
+		var v interface{}
+		if r.a != nil {
+			v = r.lookup(tkn.count, tkn.countID)
+		}
+
+		f, ferr := filterFloat(v)
+		switch {
+		case v == nil:
+			r.rs.v.add(tkn.count, ErrMissingKey)
+			return
+		case ferr != nil:
+			r.rs.v.add(tkn.count, ferr)
+			return
+		}
+
+		n = int(f)
+	}
+
+	var locale string
+	if r.rs.locale != nil {
+		locale = r.rs.locale.Name
+	}
+
+	if _, ok := o.catalog.Message(locale, tkn.id, n); !ok {
+		r.rs.v.add(tkn.id, ErrMissingMessage)
+		return
+	}
+
+	return r.processMessage(tkn)
+}
+
+// usesContext will return whether or not a template references keys of its context, other than the context itself
+func usesContext(t *Template) bool {
+	for _, tkn := range t.tkns {
+		switch tt := tkn.(type) {
+		case valToken:
+			if tt.key != "." && tt.key[0] != charAt {
+				return true
+			}
+		case sectionToken:
+			if tt.key != "." && tt.key[0] != charAt {
+				return true
+			}
+
+			if !tt.entries && usesContext(tt.t) {
+				return true
+			}
+		case invertedSectionToken:
+			if (tt.key != "." && tt.key[0] != charAt) || usesContext(tt.t) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isBool(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	return rv.Kind() == reflect.Bool
+}
@@ -0,0 +1,133 @@
+package mustache
+
+const (
+	// UsageScalar is a key used as a value, E.g. {{ name }}
+	UsageScalar Usage = 1 << iota
+	// UsageSection is a key used as a section, E.g. {{# items }}
+	UsageSection
+	// UsageInvertedSection is a key used as an inverted section, E.g. {{^ items }}
+	UsageInvertedSection
+)
+
+// Usage is how a key is used by a template. A key used in multiple ways has multiple usages set
+type Usage uint8
+
+// Has will return whether or not a usage is set
+func (u Usage) Has(usage Usage) bool {
+	return u&usage != 0
+}
+
+// Variable is a key referenced by a template
+type Variable struct {
+	// Path is the key prefixed by the sections it is nested within. Sections are suffixed with [], as
+	// they may be iterated. E.g. orders[].items[].sku
+	Path  string
+	Key   string
+	Usage Usage
+}
+
+// Variables will return every key referenced by the values, sections, inverted sections and message counts
+// of the Template, including those of its partials and layouts, in the order they are first referenced.
+// Loop metadata keys (E.g. @index) are not included, as they are not provided by the data
+func (t *Template) Variables() (vs []Variable) {
+	vc := variableCollector{idx: make(map[string]int)}
+	vc.collect(t, "")
+	return vc.vs
+}
+
+type variableCollector struct {
+	vs  []Variable
+	idx map[string]int // Index of each path within vs
+
+	partials []string // Names of the partials and layouts being collected, used to stop recursive partials
+}
+
+func (vc *variableCollector) collect(t *Template, path string) {
+	for _, tkn := range t.tkns {
+		switch tt := tkn.(type) {
+		case valToken:
+			vc.add(path, tt.key, UsageScalar)
+		case sectionToken:
+			if len(tt.partial) > 0 || tt.key[0] == charAt {
+				// Partials loaded from a file and loop metadata sections are rendered within the current context
+				vc.collect(tt.t, path)
+				break
+			}
+
+			vc.add(path, tt.key, UsageSection)
+			vc.collect(tt.t, sectionPath(path, tt.key))
+		case invertedSectionToken:
+			// Inverted sections are rendered within the current context
+			vc.add(path, tt.key, UsageInvertedSection)
+			vc.collect(tt.t, path)
+		case messageToken:
+			vc.add(path, tt.count, UsageScalar)
+		case partialToken:
+			vc.collectPartial(t.o.set, tt.name, nil, path)
+		case parentToken:
+			vc.collect(tt.t, path)
+			vc.collectPartial(t.o.set, tt.name, tt.layout, path)
+		case blockToken:
+			vc.collect(tt.t, path)
+		}
+	}
+}
+
+func (vc *variableCollector) collectPartial(s *Set, name string, t *Template, path string) {
+	for _, p := range vc.partials {
+		if p == name {
+			return
+		}
+	}
+
+	if t == nil && s != nil {
+		t, _ = s.get(name)
+	}
+
+	if t == nil {
+		return
+	}
+
+	vc.partials = append(vc.partials, name)
+	vc.collect(t, path)
+	vc.partials = vc.partials[:len(vc.partials)-1]
+}
+
+func (vc *variableCollector) add(path, key string, u Usage) {
+	if len(key) == 0 || key[0] == charAt || (key == "." && len(path) == 0) {
+		return
+	}
+
+	fp := keyPath(path, key)
+	if i, ok := vc.idx[fp]; ok {
+		vc.vs[i].Usage |= u
+		return
+	}
+
+	vc.idx[fp] = len(vc.vs)
+	vc.vs = append(vc.vs, Variable{Path: fp, Key: key, Usage: u})
+}
+
+// keyPath will return the path of a key within the context of a path
+func keyPath(path, key string) string {
+	switch {
+	case key == ".":
+		return path
+	case len(path) > 0:
+		return path + "." + key
+	}
+
+	return key
+}
+
+// sectionPath will return the path of the context of a section
+func sectionPath(path, key string) string {
+	switch {
+	case key == ".":
+		return path + "[]"
+	case len(path) > 0:
+		return path + "." + key + "[]"
+	}
+
+	return key + "[]"
+}
@@ -0,0 +1,138 @@
+package mustache
+
+import (
+	"io/fs"
+	"sync"
+	"time"
+)
+
+func newSource(fsys fs.FS, exts []string) *source {
+	return &source{
+		fsys:  fsys,
+		exts:  exts,
+		files: make(map[string]fileStamp),
+	}
+}
+
+// source is a filesystem a Set was parsed from
+type source struct {
+	fsys  fs.FS
+	exts  []string
+	files map[string]fileStamp
+}
+
+// walk will call fn for every template file within the source
+func (src *source) walk(fn func(fp string, st fileStamp) error) error {
+	return fs.WalkDir(src.fsys, ".", func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !hasExt(fp, src.exts) {
+			return nil
+		}
+
+		var fi fs.FileInfo
+		if fi, err = d.Info(); err != nil {
+			return err
+		}
+
+		return fn(fp, fileStamp{modTime: fi.ModTime(), size: fi.Size()})
+	})
+}
+
+// fileStamp is used to determine if a file has changed
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reload will reparse the templates whose files have been added or changed since they were last parsed, and
+// remove the templates whose files have been removed. Templates reference partials and layouts by name, so
+// the templates which include a changed template are updated without being reparsed.
+// A parse error is returned by the next Execute of the template, the previous version of the template is
+// retained for Lookup
+func (s *Set) Reload() (err error) {
+	s.rmux.Lock()
+	defer s.rmux.Unlock()
+
+	s.mux.RLock()
+	srcs := s.srcs
+	s.mux.RUnlock()
+
+	for _, src := range srcs {
+		if err = s.reload(src); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (s *Set) reload(src *source) (err error) {
+	seen := make(map[string]struct{}, len(src.files))
+	if err = src.walk(func(fp string, st fileStamp) error {
+		seen[fp] = struct{}{}
+		if prev, ok := src.files[fp]; ok && prev == st {
+			return nil
+		}
+
+		src.files[fp] = st
+
+		tmpl, err := fs.ReadFile(src.fsys, fp)
+		if err == nil {
+			err = s.Add(templateName(fp), tmpl)
+		}
+
+		if err != nil {
+			s.mux.Lock()
+			s.errs[templateName(fp)] = err
+			s.mux.Unlock()
+		}
+
+		return nil
+	}); err != nil {
+		return
+	}
+
+	for fp := range src.files {
+		if _, ok := seen[fp]; ok {
+			continue
+		}
+
+		delete(src.files, fp)
+
+		s.mux.Lock()
+		delete(s.ts, templateName(fp))
+		delete(s.errs, templateName(fp))
+		s.mux.Unlock()
+	}
+
+	return
+}
+
+// Watch is a development mode which polls the filesystems of the Set every interval, calling Reload.
+// Errors from walking the filesystems are passed to onErr, when provided. The returned func stops watching
+func (s *Set) Watch(interval time.Duration, onErr func(error)) (stop func()) {
+	var once sync.Once
+	done := make(chan struct{})
+	go func() {
+		tkr := time.NewTicker(interval)
+		defer tkr.Stop()
+
+		for {
+			select {
+			case <-tkr.C:
+				if err := s.Reload(); err != nil && onErr != nil {
+					onErr(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}